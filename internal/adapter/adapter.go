@@ -1,46 +1,101 @@
 package adapter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/invalidmsg"
 	"github.com/ansig/jetstream-cdevents-sink/internal/translator"
 	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
 
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
 	"github.com/nats-io/nats.go"
+	natsjs "github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 var (
 	ErrInvalidSubject    error = errors.New("Message subject is invalid")
+	ErrInvalidPayload    error = errors.New("Message payload is not valid JSON")
 	ErrNoTranslator      error = errors.New("No translator found")
 	ErrTranslationFailed error = errors.New("Could not translate event")
 	ErrPublishFailed     error = errors.New("Failed to publish event")
+	ErrMaxDeliveries     error = errors.New("Message exceeded max deliveries")
 )
 
+// RetryPolicy decides how messages that failed for transient reasons are
+// redelivered. MaxDeliver should match that of the consumer, a value of zero
+// or less means that messages are retried indefinitely.
+type RetryPolicy struct {
+	MaxDeliver   int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// Delay returns the exponential back-off to apply before the next delivery of
+// a message that has already been delivered the given number of times.
+func (r RetryPolicy) Delay(numDelivered uint64) time.Duration {
+	delay := r.InitialDelay
+	for i := uint64(1); i < numDelivered; i++ {
+		if delay >= r.MaxDelay/2 {
+			return r.MaxDelay
+		}
+		delay *= 2
+	}
+	return min(delay, r.MaxDelay)
+}
+
+func (r RetryPolicy) isFinalDelivery(numDelivered uint64) bool {
+	return r.MaxDeliver > 0 && numDelivered >= uint64(r.MaxDeliver)
+}
+
 type CDEvents struct {
 	logger        *slog.Logger
 	publisher     transport.CloudEventPublisher
 	invMsgHandler invalidmsg.Handler
 	translators   map[string]translator.Webhook
+	retryPolicy   RetryPolicy
+	schemaBaseURL string
+	ignored       *prometheus.CounterVec
+	dropped       prometheus.Counter
 }
 
-func New(logger *slog.Logger, nc *nats.Conn, translators map[string]translator.Webhook, invMsgHandler invalidmsg.Handler) *CDEvents {
+func New(logger *slog.Logger, registry prometheus.Registerer, publisher transport.CloudEventPublisher, translators map[string]translator.Webhook, invMsgHandler invalidmsg.Handler, retryPolicy RetryPolicy, schemaBaseURL string) *CDEvents {
 	return &CDEvents{
 		logger:        logger,
-		publisher:     transport.NewCloudEventJetStreamPublisher(nc),
+		publisher:     publisher,
 		translators:   translators,
 		invMsgHandler: invMsgHandler,
+		retryPolicy:   retryPolicy,
+		schemaBaseURL: strings.TrimSuffix(schemaBaseURL, "/"),
+		ignored: promauto.With(registry).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "adapter_webhooks_ignored_total",
+				Help: "Tracks the number of webhook messages acknowledged without publishing any events.",
+			}, []string{"translator", "reason"},
+		),
+		dropped: promauto.With(registry).NewCounter(
+			prometheus.CounterOpts{
+				Name: "adapter_webhooks_dropped_total",
+				Help: "Tracks the number of webhook messages that failed and could not be handed to the invalid message handler either.",
+			},
+		),
 	}
 }
 
+// Process translates the message and publishes the resulting CDEvents. The
+// message is only acknowledged once all events are published or the message has
+// been handed to the invalid message handler, transient failures are negatively
+// acknowledged so that the message is redelivered after a back-off.
 func (c *CDEvents) Process(msg transport.JetstreamMsg) error {
 
-	defer msg.Ack()
-
 	metadata, err := msg.Metadata()
 	if err != nil {
 		return err
@@ -55,52 +110,204 @@ func (c *CDEvents) Process(msg transport.JetstreamMsg) error {
 
 	var v map[string]interface{}
 	if err := json.Unmarshal(msg.Data(), &v); err != nil {
-		return err
+		c.logger.Error("Message payload is not valid JSON", "subject", msg.Subject(), "error", err)
+		return c.reject(msg, metadata, fmt.Errorf("%w: %w", ErrInvalidPayload, err))
 	}
 
 	subjectParts := strings.Split(msg.Subject(), ".")
 	if len(subjectParts) < 2 {
 		c.logger.Error(fmt.Sprintf("Unable to determine type of message as subject has to few parts: %s", msg.Subject()))
-		if err := c.invMsgHandler.Receive(msg, ErrInvalidSubject); err != nil {
-			return err
-		}
-		return nil
+		return c.reject(msg, metadata, ErrInvalidSubject)
 	}
 
 	eventSubject := strings.Join(subjectParts[1:], ".")
-	translator, exists := c.translators[eventSubject]
+	webhookTranslator, exists := c.translators[eventSubject]
 	if !exists {
 		c.logger.Error(fmt.Sprintf("No translator found for subject: %s", eventSubject))
-		if err := c.invMsgHandler.Receive(msg, ErrNoTranslator); err != nil {
-			return err
-		}
-		return nil
+		return c.reject(msg, metadata, ErrNoTranslator)
 	}
 
-	cdEvent, err := translator.Translate(msg.Data())
+	cdEvents, err := webhookTranslator.Translate(translator.Request{
+		Payload:       msg.Data(),
+		Headers:       http.Header(msg.Headers()),
+		Subject:       msg.Subject(),
+		Metadata:      metadata,
+		FinalDelivery: c.retryPolicy.isFinalDelivery(metadata.NumDelivered),
+	})
+	if errors.Is(err, translator.ErrIgnored) {
+		reason := translator.IgnoredReason(err)
+		if reason == "" {
+			reason = "other"
+		}
+		c.logger.Debug("Ignoring webhook message", "subject", msg.Subject(), "reason", reason, "error", err)
+		c.ignored.WithLabelValues(eventSubject, reason).Inc()
+		return msg.Ack()
+	}
+	if errors.Is(err, translator.ErrTemporary) {
+		c.logger.Warn("Event cannot be translated for now", "subject", msg.Subject(), "error", err, "num_delivered", metadata.NumDelivered)
+		if c.retryPolicy.isFinalDelivery(metadata.NumDelivered) {
+			return c.reject(msg, metadata, fmt.Errorf("%w: %w", ErrTranslationFailed, err))
+		}
+		return c.retry(msg, metadata, fmt.Errorf("%w: %w", ErrTranslationFailed, err))
+	}
 	if err != nil {
 		c.logger.Error("Failed to translate event", "error", err)
-		if err := c.invMsgHandler.Receive(msg, ErrTranslationFailed); err != nil {
-			return err
+		return c.reject(msg, metadata, ErrTranslationFailed)
+	}
+
+	if len(cdEvents) == 0 {
+		c.logger.Debug("Webhook message translated into no CDEvents", "subject", msg.Subject())
+		c.ignored.WithLabelValues(eventSubject, "no_events").Inc()
+		return msg.Ack()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Should publishing any of the events fail, the message is redelivered and
+	// all of its events are published again.
+	for _, cdEvent := range cdEvents {
+
+		// Translators date events by the source system when the payload has a
+		// timestamp. Otherwise the event is dated by when the webhook was
+		// received, which it should never be later than.
+		if !metadata.Timestamp.IsZero() && cdEvent.GetTimestamp().After(metadata.Timestamp) {
+			cdEvent.SetTimestamp(metadata.Timestamp)
 		}
-		return nil
+
+		c.logger.Debug("Translated incoming webhook message into CDEvent",
+			"type", cdEvent.GetType(),
+			"subject", msg.Subject(),
+			"stream_seq", metadata.Sequence.Stream,
+			"num_delivered", metadata.NumDelivered,
+			"stream", metadata.Stream,
+			"consumer", metadata.Consumer)
+
+		pubAck, err := c.publisher.Publish(ctx, cdEvent, transport.WithIngestTime(metadata.Timestamp), transport.WithDataSchema(c.dataSchema(cdEvent)))
+		if err != nil {
+			c.logger.Error("Failed to publish CDEvent", "error", err, "num_delivered", metadata.NumDelivered)
+			if c.retryPolicy.isFinalDelivery(metadata.NumDelivered) {
+				return c.reject(msg, metadata, fmt.Errorf("%w: %w", ErrPublishFailed, err))
+			}
+			return c.retry(msg, metadata, fmt.Errorf("%w: %w", ErrPublishFailed, err))
+		}
+
+		c.logger.Debug("Published CDEvent",
+			"type", cdEvent.GetType(),
+			"id", cdEvent.GetId(),
+			"event_stream", pubAck.Stream,
+			"event_stream_seq", pubAck.Sequence)
 	}
 
-	c.logger.Debug("Translated incoming webhook message into CDEvent",
-		"type", cdEvent.GetType(),
-		"subject", msg.Subject(),
-		"stream_seq", metadata.Sequence.Stream,
-		"num_delivered", metadata.NumDelivered,
-		"stream", metadata.Stream,
-		"consumer", metadata.Consumer)
+	return msg.Ack()
+}
+
+// dataSchema returns the URI of the schema of the custom data of the event, as
+// served by the adapter, or an empty string when no base URL is configured or
+// the event has no custom data of a known kind.
+func (c *CDEvents) dataSchema(cdEvent cdevents.CDEvent) string {
+	path := translator.CustomDataSchemaPath(cdEvent)
+	if c.schemaBaseURL == "" || path == "" {
+		return ""
+	}
+	return c.schemaBaseURL + path
+}
 
-	if err := c.publisher.Publish(cdEvent); err != nil {
-		c.logger.Error("Failed to publish CDEvent", "error", err)
-		if err := c.invMsgHandler.Receive(msg, ErrPublishFailed); err != nil {
-			return err
+// reject hands a message that can never be processed to the invalid message
+// handler and acknowledges it. Should the handler fail the message is retried,
+// unless this was its final delivery, in which case it is dropped.
+func (c *CDEvents) reject(msg transport.JetstreamMsg, metadata *natsjs.MsgMetadata, reason error) error {
+	if err := c.invMsgHandler.Receive(msg, reason); err != nil {
+		if c.retryPolicy.isFinalDelivery(metadata.NumDelivered) {
+			c.drop(msg.Subject(), metadata.Sequence.Stream, errors.Join(reason, err))
+			return errors.Join(reason, err, msg.Term())
 		}
-		return nil
+		return c.retry(msg, metadata, errors.Join(reason, err))
 	}
+	return msg.Ack()
+}
+
+// drop records that a failed message is lost without reaching the invalid
+// message handler.
+func (c *CDEvents) drop(subject string, streamSeq uint64, reason error) {
+	c.logger.Error("Dropping message that could not be handed to the invalid message handler", "subject", subject, "stream_seq", streamSeq, "error", reason)
+	c.dropped.Inc()
+}
+
+// retry negatively acknowledges the message so that it is redelivered after
+// the back-off given by the retry policy.
+func (c *CDEvents) retry(msg transport.JetstreamMsg, metadata *natsjs.MsgMetadata, reason error) error {
+	delay := c.retryPolicy.Delay(metadata.NumDelivered)
+	c.logger.Warn("Message will be retried", "subject", msg.Subject(), "delay", delay, "num_delivered", metadata.NumDelivered)
+	if err := msg.NakWithDelay(delay); err != nil {
+		return errors.Join(reason, err)
+	}
+	return reason
+}
+
+// maxDeliveriesAdvisory is published by JetStream when a message has been
+// delivered to a consumer as many times as allowed without being acknowledged,
+// on $JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES.<stream>.<consumer>.
+type maxDeliveriesAdvisory struct {
+	Stream     string `json:"stream"`
+	Consumer   string `json:"consumer"`
+	StreamSeq  uint64 `json:"stream_seq"`
+	Deliveries uint64 `json:"deliveries"`
+}
+
+// ProcessMaxDeliveries hands the message of a max deliveries advisory to the
+// invalid message handler and removes it from the stream. These are messages
+// whose deliveries all ran out of ack wait, e.g. because the adapter stopped
+// while processing them, so that their final delivery never got to reject them.
+func (c *CDEvents) ProcessMaxDeliveries(stream transport.JetstreamStream, data []byte) error {
+
+	var advisory maxDeliveriesAdvisory
+	if err := json.Unmarshal(data, &advisory); err != nil {
+		return fmt.Errorf("invalid max deliveries advisory: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	raw, err := stream.GetMsg(ctx, advisory.StreamSeq)
+	if err != nil {
+		return fmt.Errorf("failed to get message %d that exceeded max deliveries: %w", advisory.StreamSeq, err)
+	}
+
+	c.logger.Warn("Message exceeded max deliveries", "subject", raw.Subject, "stream_seq", raw.Sequence, "num_delivered", advisory.Deliveries)
+
+	msg := &storedMsg{raw: raw, stream: advisory.Stream, consumer: advisory.Consumer, numDelivered: advisory.Deliveries}
+	if err := c.invMsgHandler.Receive(msg, ErrMaxDeliveries); err != nil {
+		c.drop(raw.Subject, raw.Sequence, errors.Join(ErrMaxDeliveries, err))
+		return err
+	}
+
+	return stream.DeleteMsg(ctx, raw.Sequence)
+}
+
+// storedMsg is a message read from the stream rather than delivered by the
+// consumer, so there is no delivery to acknowledge.
+type storedMsg struct {
+	raw          *natsjs.RawStreamMsg
+	stream       string
+	consumer     string
+	numDelivered uint64
+}
 
-	return nil
+func (s *storedMsg) Data() []byte                       { return s.raw.Data }
+func (s *storedMsg) Headers() nats.Header               { return s.raw.Header }
+func (s *storedMsg) Subject() string                    { return s.raw.Subject }
+func (s *storedMsg) Ack() error                         { return nil }
+func (s *storedMsg) Nak() error                         { return nil }
+func (s *storedMsg) NakWithDelay(_ time.Duration) error { return nil }
+func (s *storedMsg) InProgress() error                  { return nil }
+func (s *storedMsg) Term() error                        { return nil }
+func (s *storedMsg) Metadata() (*natsjs.MsgMetadata, error) {
+	return &natsjs.MsgMetadata{
+		Sequence:     natsjs.SequencePair{Stream: s.raw.Sequence},
+		NumDelivered: s.numDelivered,
+		Stream:       s.stream,
+		Consumer:     s.consumer,
+		Timestamp:    s.raw.Time,
+	}, nil
 }
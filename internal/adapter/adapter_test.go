@@ -1,16 +1,22 @@
 package adapter
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"testing"
+	"time"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/mocks"
 	"github.com/ansig/jetstream-cdevents-sink/internal/translator"
 	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
 	cdevents "github.com/cdevents/sdk-go/pkg/api"
 	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -23,46 +29,90 @@ func TestProcess(t *testing.T) {
 	changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
 	require.NoError(t, err, "unable to create CDEvent for tests")
 
+	changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+
 	validMsgData := []byte("{\"foo\": \"bar\"}")
 
 	webhookTestEventMsg := mocks.NewJetstreamMsg("webhook.test.event", validMsgData)
+	webhookTestEventMsg.Header = nats.Header{"X-Gitea-Delivery": []string{"f6266f16-1bf3-46a5-9ea4-602e06ead473"}}
 	webhookTestUnknownMsg := mocks.NewJetstreamMsg("webhook.unknown", validMsgData)
 	invalidSubjectMsg := mocks.NewJetstreamMsg("invalid", validMsgData)
+	invalidDataMsg := mocks.NewJetstreamMsg("webhook.test.event", []byte("notjson"))
+
+	retryPolicy := RetryPolicy{MaxDeliver: 3, InitialDelay: time.Second, MaxDelay: time.Minute}
+
+	isPublishFailure := mock.MatchedBy(func(err error) bool { return errors.Is(err, ErrPublishFailed) })
+	isTemporaryFailure := mock.MatchedBy(func(err error) bool { return errors.Is(err, translator.ErrTemporary) })
+	isInvalidPayload := mock.MatchedBy(func(err error) bool { return errors.Is(err, ErrInvalidPayload) })
 
 	for _, tc := range []struct {
 		title                     string
-		incomingMsg               transport.JetstreamMsg
+		incomingMsg               *mocks.JetstreamMsg
+		numDelivered              uint64
 		translatorSubject         string
-		translatedEvent           cdevents.CDEvent
+		translatedEvents          []cdevents.CDEvent
 		translatorError           error
 		publisherError            error
 		invalidMsgHandlerError    error
 		expectedError             error
 		expectedDataTranslated    []byte
-		expectedEventPublished    cdevents.CDEvent
+		expectedEventsPublished   []cdevents.CDEvent
 		expectedInvMsgHandlerArgs []interface{}
-		expectedMsgSentToHandler  transport.JetstreamMsg
-		expectedErrSentToHandler  error
+		expectedAcked             bool
+		expectedDropped           bool
+		expectedIgnoredReason     string
+		expectedNakDelay          time.Duration
 	}{
 		{
-			title:                  "translates message data and publishes translated event",
+			title:                   "translates message data and publishes translated event",
+			incomingMsg:             webhookTestEventMsg,
+			translatorSubject:       "test.event",
+			translatedEvents:        []cdevents.CDEvent{changeMergedEvent},
+			expectedDataTranslated:  webhookTestEventMsg.Data(),
+			expectedEventsPublished: []cdevents.CDEvent{changeMergedEvent},
+			expectedAcked:           true,
+		},
+		{
+			title:                   "tells translator that message is on its final delivery",
+			incomingMsg:             webhookTestEventMsg,
+			numDelivered:            3,
+			translatorSubject:       "test.event",
+			translatedEvents:        []cdevents.CDEvent{changeMergedEvent},
+			expectedDataTranslated:  webhookTestEventMsg.Data(),
+			expectedEventsPublished: []cdevents.CDEvent{changeMergedEvent},
+			expectedAcked:           true,
+		},
+		{
+			title:                   "publishes all translated events",
+			incomingMsg:             webhookTestEventMsg,
+			translatorSubject:       "test.event",
+			translatedEvents:        []cdevents.CDEvent{changeMergedEvent, changeUpdatedEvent},
+			expectedDataTranslated:  webhookTestEventMsg.Data(),
+			expectedEventsPublished: []cdevents.CDEvent{changeMergedEvent, changeUpdatedEvent},
+			expectedAcked:           true,
+		},
+		{
+			title:                  "ack without publishing when translated into no events",
 			incomingMsg:            webhookTestEventMsg,
 			translatorSubject:      "test.event",
-			translatedEvent:        changeMergedEvent,
 			expectedDataTranslated: webhookTestEventMsg.Data(),
-			expectedEventPublished: changeMergedEvent,
+			expectedAcked:          true,
+			expectedIgnoredReason:  "no_events",
 		},
 		{
 			title:                     "send to invalid msg handler when no translator matching subject",
 			incomingMsg:               webhookTestUnknownMsg,
 			translatorSubject:         "test.somethingelse", // different from that of webhoostTestUnkownMsg
 			expectedInvMsgHandlerArgs: []interface{}{webhookTestUnknownMsg, ErrNoTranslator},
+			expectedAcked:             true,
 		},
 		{
 			title:                     "send to invalid msg handler on less than 2 subject parts",
 			incomingMsg:               invalidSubjectMsg,
 			translatorSubject:         "test.event",
 			expectedInvMsgHandlerArgs: []interface{}{invalidSubjectMsg, ErrInvalidSubject},
+			expectedAcked:             true,
 		},
 		{
 			title:                     "send to invalid msg handler when translator returns error",
@@ -70,32 +120,128 @@ func TestProcess(t *testing.T) {
 			translatorSubject:         "test.event",
 			translatorError:           fmt.Errorf("something went wrong in translating the event"),
 			expectedInvMsgHandlerArgs: []interface{}{webhookTestEventMsg, ErrTranslationFailed},
+			expectedAcked:             true,
 		},
 		{
-			title:                     "send to invalid msg handler when publish returns error",
+			title:             "nak with back-off when translator returns temporary error",
+			incomingMsg:       webhookTestEventMsg,
+			numDelivered:      2,
+			translatorSubject: "test.event",
+			translatorError:   translator.Temporary(fmt.Errorf("connection closed")),
+			expectedError:     translator.ErrTemporary,
+			expectedNakDelay:  2 * time.Second,
+		},
+		{
+			title:                     "send to invalid msg handler when translator returns temporary error on final delivery",
 			incomingMsg:               webhookTestEventMsg,
+			numDelivered:              3,
 			translatorSubject:         "test.event",
-			translatedEvent:           changeMergedEvent,
+			translatorError:           translator.Temporary(fmt.Errorf("connection closed")),
+			expectedInvMsgHandlerArgs: []interface{}{webhookTestEventMsg, isTemporaryFailure},
+			expectedAcked:             true,
+		},
+		{
+			title:                 "ack without publishing when translator ignores event",
+			incomingMsg:           webhookTestEventMsg,
+			translatorSubject:     "test.event",
+			translatorError:       translator.Ignore(translator.ReasonNoCommits, "not interesting"),
+			expectedAcked:         true,
+			expectedIgnoredReason: translator.ReasonNoCommits,
+		},
+		{
+			title:                 "ack without publishing when translator ignores unsupported action",
+			incomingMsg:           webhookTestEventMsg,
+			translatorSubject:     "test.event",
+			translatorError:       translator.Ignore(translator.ReasonUnsupportedAction, "assigned has no events"),
+			expectedAcked:         true,
+			expectedIgnoredReason: translator.ReasonUnsupportedAction,
+		},
+		{
+			title:                     "send to invalid msg handler when translator does not support action",
+			incomingMsg:               webhookTestEventMsg,
+			translatorSubject:         "test.event",
+			translatorError:           translator.ErrUnsupportedAction,
+			expectedInvMsgHandlerArgs: []interface{}{webhookTestEventMsg, ErrTranslationFailed},
+			expectedAcked:             true,
+		},
+		{
+			title:                 "ack without publishing when translator ignores event without reason",
+			incomingMsg:           webhookTestEventMsg,
+			translatorSubject:     "test.event",
+			translatorError:       fmt.Errorf("%w: not interesting", translator.ErrIgnored),
+			expectedAcked:         true,
+			expectedIgnoredReason: "other",
+		},
+		{
+			title:             "nak with back-off when publish returns error",
+			incomingMsg:       webhookTestEventMsg,
+			numDelivered:      2,
+			translatorSubject: "test.event",
+			translatedEvents:  []cdevents.CDEvent{changeMergedEvent},
+			publisherError:    fmt.Errorf("something went wrong when publishing the event"),
+			expectedError:     ErrPublishFailed,
+			expectedNakDelay:  2 * time.Second,
+		},
+		{
+			title:                     "send to invalid msg handler when publish returns error on final delivery",
+			incomingMsg:               webhookTestEventMsg,
+			numDelivered:              3,
+			translatorSubject:         "test.event",
+			translatedEvents:          []cdevents.CDEvent{changeMergedEvent},
 			publisherError:            fmt.Errorf("something went wrong when publishing the event"),
-			expectedInvMsgHandlerArgs: []interface{}{webhookTestEventMsg, ErrPublishFailed},
+			expectedInvMsgHandlerArgs: []interface{}{webhookTestEventMsg, isPublishFailure},
+			expectedAcked:             true,
+		},
+		{
+			title:                     "nak with back-off when invalid msg handler returns error",
+			incomingMsg:               webhookTestUnknownMsg,
+			numDelivered:              1,
+			translatorSubject:         "test.event",
+			invalidMsgHandlerError:    fmt.Errorf("something went wrong when publishing the invalid message"),
+			expectedError:             ErrNoTranslator,
+			expectedInvMsgHandlerArgs: []interface{}{webhookTestUnknownMsg, ErrNoTranslator},
+			expectedNakDelay:          time.Second,
+		},
+		{
+			title:                     "drop message when invalid msg handler returns error on final delivery",
+			incomingMsg:               webhookTestUnknownMsg,
+			numDelivered:              3,
+			translatorSubject:         "test.event",
+			invalidMsgHandlerError:    fmt.Errorf("something went wrong when publishing the invalid message"),
+			expectedError:             ErrNoTranslator,
+			expectedInvMsgHandlerArgs: []interface{}{webhookTestUnknownMsg, ErrNoTranslator},
+			expectedDropped:           true,
+		},
+		{
+			title:                     "send to invalid msg handler when message data is not valid JSON",
+			incomingMsg:               invalidDataMsg,
+			numDelivered:              1,
+			translatorSubject:         "test.event",
+			expectedInvMsgHandlerArgs: []interface{}{invalidDataMsg, isInvalidPayload},
+			expectedAcked:             true,
 		},
 	} {
 		t.Run(tc.title, func(t *testing.T) {
+			tc.incomingMsg.Acked = false
+			tc.incomingMsg.Naked = false
+			tc.incomingMsg.NakDelay = 0
+			tc.incomingMsg.Termed = false
+			tc.incomingMsg.NumDelivered = tc.numDelivered
+
 			mockPublisher := &mocks.CloudEventPublisher{}
-			mockPublisher.On("Publish", mock.Anything).Return(tc.publisherError)
+			if tc.publisherError != nil {
+				mockPublisher.On("Publish", mock.Anything).Return(nil, tc.publisherError)
+			} else {
+				mockPublisher.On("Publish", mock.Anything).Return(&jetstream.PubAck{Stream: "mockStream", Sequence: 1}, nil)
+			}
 
 			mockTranslator := &mocks.WebhookTranslator{}
-			mockTranslator.On("Translate", mock.Anything).Return(tc.translatedEvent, tc.translatorError)
+			mockTranslator.On("Translate", mock.Anything).Return(tc.translatedEvents, tc.translatorError)
 
 			mockInvMsgHandler := &mocks.InvalidMessageHandler{}
 			mockInvMsgHandler.On("Receive", mock.Anything, mock.Anything).Return(tc.invalidMsgHandlerError)
 
-			adapter := &CDEvents{
-				logger:        logger,
-				publisher:     mockPublisher,
-				invMsgHandler: mockInvMsgHandler,
-				translators:   map[string]translator.Webhook{tc.translatorSubject: mockTranslator},
-			}
+			adapter := New(logger, prometheus.NewRegistry(), mockPublisher, map[string]translator.Webhook{tc.translatorSubject: mockTranslator}, mockInvMsgHandler, retryPolicy, "")
 
 			err = adapter.Process(tc.incomingMsg)
 
@@ -106,16 +252,240 @@ func TestProcess(t *testing.T) {
 			}
 
 			if tc.expectedDataTranslated != nil {
-				mockTranslator.AssertCalled(t, "Translate", tc.expectedDataTranslated)
+				mockTranslator.AssertCalled(t, "Translate", mock.MatchedBy(func(req translator.Request) bool {
+					return string(req.Payload) == string(tc.expectedDataTranslated) &&
+						req.Subject == tc.incomingMsg.Subject() &&
+						req.Headers.Get("X-Gitea-Delivery") == tc.incomingMsg.Header.Get("X-Gitea-Delivery") &&
+						req.Metadata != nil &&
+						req.FinalDelivery == (tc.numDelivered == uint64(retryPolicy.MaxDeliver))
+				}))
 			}
 
-			if tc.expectedEventPublished != nil {
-				mockPublisher.AssertCalled(t, "Publish", tc.expectedEventPublished)
+			for _, cdEvent := range tc.expectedEventsPublished {
+				mockPublisher.AssertCalled(t, "Publish", cdEvent)
+			}
+			if tc.publisherError == nil {
+				mockPublisher.AssertNumberOfCalls(t, "Publish", len(tc.expectedEventsPublished))
 			}
 
 			if tc.expectedInvMsgHandlerArgs != nil {
 				mockInvMsgHandler.AssertCalled(t, "Receive", tc.expectedInvMsgHandlerArgs...)
+			} else {
+				mockInvMsgHandler.AssertNotCalled(t, "Receive", mock.Anything, mock.Anything)
+			}
+
+			if tc.expectedIgnoredReason != "" {
+				assert.Equal(t, 1.0, testutil.ToFloat64(adapter.ignored.WithLabelValues("test.event", tc.expectedIgnoredReason)), "ignored messages")
+			} else {
+				assert.Equal(t, 0, testutil.CollectAndCount(adapter.ignored), "ignored messages")
+			}
+
+			assert.Equal(t, tc.expectedAcked, tc.incomingMsg.Acked, "message acknowledgement")
+			assert.Equal(t, tc.expectedDropped, tc.incomingMsg.Termed, "message termination")
+			if tc.expectedDropped {
+				assert.Equal(t, 1.0, testutil.ToFloat64(adapter.dropped), "dropped messages")
+			} else {
+				assert.Equal(t, 0.0, testutil.ToFloat64(adapter.dropped), "dropped messages")
+			}
+			assert.Equal(t, tc.expectedNakDelay != 0, tc.incomingMsg.Naked, "message negative acknowledgement")
+			assert.Equal(t, tc.expectedNakDelay, tc.incomingMsg.NakDelay, "delay before redelivery")
+		})
+	}
+}
+
+func TestProcessTimestamp(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	receivedAt := time.Date(2025, 2, 12, 10, 0, 0, 0, time.UTC)
+	committedAt := receivedAt.Add(-time.Minute)
+
+	for _, tc := range []struct {
+		title             string
+		sourceTimestamp   time.Time
+		expectedTimestamp time.Time
+	}{
+		{
+			title:             "keeps timestamp from source system",
+			sourceTimestamp:   committedAt,
+			expectedTimestamp: committedAt,
+		},
+		{
+			title:             "falls back to time the message was received",
+			expectedTimestamp: receivedAt,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+			require.NoError(t, err, "unable to create CDEvent for tests")
+			if !tc.sourceTimestamp.IsZero() {
+				changeMergedEvent.SetTimestamp(tc.sourceTimestamp)
 			}
+
+			msg := mocks.NewJetstreamMsg("webhook.test.event", []byte("{\"foo\": \"bar\"}"))
+			msg.Timestamp = receivedAt
+
+			mockPublisher := &mocks.CloudEventPublisher{}
+			mockPublisher.On("Publish", mock.Anything).Return(&jetstream.PubAck{Stream: "mockStream", Sequence: 1}, nil)
+
+			mockTranslator := &mocks.WebhookTranslator{}
+			mockTranslator.On("Translate", mock.Anything).Return([]cdevents.CDEvent{changeMergedEvent}, nil)
+
+			adapter := New(logger, prometheus.NewRegistry(), mockPublisher, map[string]translator.Webhook{"test.event": mockTranslator}, &mocks.InvalidMessageHandler{}, RetryPolicy{}, "")
+
+			require.NoError(t, adapter.Process(msg), "no error should be returned")
+
+			published := mockPublisher.Calls[0].Arguments.Get(0).(cdevents.CDEvent)
+			assert.Equal(t, tc.expectedTimestamp, published.GetTimestamp(), "event timestamp")
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+
+	retryPolicy := RetryPolicy{InitialDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for numDelivered, expectedDelay := range map[uint64]time.Duration{
+		1:  time.Second,
+		2:  2 * time.Second,
+		3:  4 * time.Second,
+		4:  8 * time.Second,
+		5:  10 * time.Second,
+		80: 10 * time.Second,
+	} {
+		assert.Equal(t, expectedDelay, retryPolicy.Delay(numDelivered), fmt.Sprintf("delay after %d deliveries", numDelivered))
+	}
+}
+
+func TestProcessMaxDeliveries(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	receivedAt := time.Date(2025, 2, 12, 10, 0, 0, 0, time.UTC)
+	advisory := []byte(`{"type": "io.nats.jetstream.advisory.v1.max_deliver", "stream": "webhooks", "consumer": "adapter", "stream_seq": 42, "deliveries": 5}`)
+	storedMsg := &jetstream.RawStreamMsg{Subject: "webhooks.gitea.push", Sequence: 42, Data: []byte(`{"foo": "bar"}`), Time: receivedAt}
+
+	isStoredMsg := mock.MatchedBy(func(msg transport.JetstreamMsg) bool {
+		metadata, _ := msg.Metadata()
+		return msg.Subject() == "webhooks.gitea.push" && string(msg.Data()) == `{"foo": "bar"}` &&
+			metadata.Sequence.Stream == 42 && metadata.NumDelivered == 5 && metadata.Timestamp.Equal(receivedAt)
+	})
+
+	for _, tc := range []struct {
+		title                  string
+		getMsgError            error
+		invalidMsgHandlerError error
+		expectedError          bool
+		expectedReceived       bool
+		expectedDeleted        bool
+		expectedDropped        float64
+	}{
+		{
+			title:            "sends message to invalid msg handler and deletes it",
+			expectedReceived: true,
+			expectedDeleted:  true,
+		},
+		{
+			title:                  "drops message when invalid msg handler returns error",
+			invalidMsgHandlerError: fmt.Errorf("something went wrong when publishing the invalid message"),
+			expectedError:          true,
+			expectedReceived:       true,
+			expectedDropped:        1,
+		},
+		{
+			title:         "error when message cannot be read from stream",
+			getMsgError:   jetstream.ErrMsgNotFound,
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			mockStream := &mocks.JetstreamStream{}
+			if tc.getMsgError != nil {
+				mockStream.On("GetMsg", uint64(42)).Return(nil, tc.getMsgError)
+			} else {
+				mockStream.On("GetMsg", uint64(42)).Return(storedMsg, nil)
+			}
+			mockStream.On("DeleteMsg", uint64(42)).Return(nil)
+
+			mockInvMsgHandler := &mocks.InvalidMessageHandler{}
+			mockInvMsgHandler.On("Receive", mock.Anything, mock.Anything).Return(tc.invalidMsgHandlerError)
+
+			adapter := New(logger, prometheus.NewRegistry(), &mocks.CloudEventPublisher{}, map[string]translator.Webhook{}, mockInvMsgHandler, RetryPolicy{}, "")
+
+			err := adapter.ProcessMaxDeliveries(mockStream, advisory)
+
+			if tc.expectedError {
+				assert.Error(t, err, "error should be returned")
+			} else {
+				require.NoError(t, err, "no error should be returned")
+			}
+
+			if tc.expectedReceived {
+				mockInvMsgHandler.AssertCalled(t, "Receive", isStoredMsg, ErrMaxDeliveries)
+			} else {
+				mockInvMsgHandler.AssertNotCalled(t, "Receive", mock.Anything, mock.Anything)
+			}
+
+			if tc.expectedDeleted {
+				mockStream.AssertCalled(t, "DeleteMsg", uint64(42))
+			} else {
+				mockStream.AssertNotCalled(t, "DeleteMsg", mock.Anything)
+			}
+
+			assert.Equal(t, tc.expectedDropped, testutil.ToFloat64(adapter.dropped), "dropped messages")
+		})
+	}
+}
+
+func TestDataSchema(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	newEvent := func(customData interface{}) cdevents.CDEvent {
+		cdEvent, err := cdeventsv04.NewChangeMergedEvent()
+		require.NoError(t, err, "unable to create CDEvent for tests")
+		if customData != nil {
+			require.NoError(t, cdEvent.SetCustomData("application/json", customData), "unable to set custom data for tests")
+		}
+		return cdEvent
+	}
+
+	for _, tc := range []struct {
+		title          string
+		schemaBaseURL  string
+		event          cdevents.CDEvent
+		expectedSchema string
+	}{
+		{
+			title:          "schema of custom data kind",
+			schemaBaseURL:  "https://adapter.example.com",
+			event:          newEvent(map[string]interface{}{"Kind": "gitea.push/v1", "Content": map[string]interface{}{}}),
+			expectedSchema: "https://adapter.example.com/schemas/customdata/gitea.push/v1.json",
+		},
+		{
+			title:          "base URL with trailing slash",
+			schemaBaseURL:  "https://adapter.example.com/",
+			event:          newEvent(map[string]interface{}{"Kind": "gitlab.merge_request/v1", "Content": map[string]interface{}{}}),
+			expectedSchema: "https://adapter.example.com/schemas/customdata/gitlab.merge_request/v1.json",
+		},
+		{
+			title: "no schema without base URL",
+			event: newEvent(map[string]interface{}{"Kind": "gitea.push/v1", "Content": map[string]interface{}{}}),
+		},
+		{
+			title:         "no schema without custom data",
+			schemaBaseURL: "https://adapter.example.com",
+			event:         newEvent(nil),
+		},
+		{
+			title:         "no schema for unknown kind",
+			schemaBaseURL: "https://adapter.example.com",
+			event:         newEvent(map[string]interface{}{"Kind": "deployment", "Content": map[string]interface{}{}}),
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			adapter := New(logger, prometheus.NewRegistry(), &mocks.CloudEventPublisher{}, map[string]translator.Webhook{}, &mocks.InvalidMessageHandler{}, RetryPolicy{}, tc.schemaBaseURL)
+			assert.Equal(t, tc.expectedSchema, adapter.dataSchema(tc.event))
 		})
 	}
 }
@@ -0,0 +1,346 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/translator"
+	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
+
+	natsjs "github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type Processor interface {
+	Process(msg transport.JetstreamMsg) error
+}
+
+// Pool processes messages concurrently on a fixed number of workers. Messages
+// with the same ordering key are always handled by the same worker, so that
+// they are processed in the order in which they arrived.
+//
+// To keep that order, a message that the processor negatively acknowledges is
+// retried by its worker once the back-off has passed, before it moves on to the
+// next message. Each retry counts as a delivery towards the retry policy. The
+// exception is a push waiting for the pull request of its merge, which is handed
+// back to JetStream so that it does not hold back that pull request.
+type Pool struct {
+	logger    *slog.Logger
+	processor Processor
+	key       func(msg transport.JetstreamMsg) string
+	queues    []chan transport.JetstreamMsg
+	next      atomic.Uint64
+	mu        sync.RWMutex
+	stopped   bool
+	stopping  chan struct{}
+	wg        sync.WaitGroup
+
+	inProgressInterval time.Duration
+	waitingMu          sync.Mutex
+	waiting            map[transport.JetstreamMsg]struct{}
+	stopHeartbeat      chan struct{}
+	heartbeat          sync.WaitGroup
+
+	queueDepth  *prometheus.GaugeVec
+	busyWorkers prometheus.Gauge
+	busySeconds prometheus.Counter
+}
+
+// NewPool creates a pool with the given number of workers, each with a queue
+// holding up to queueSize messages, ordering messages by RepositoryKey. Messages
+// waiting in the queues or to be retried are reported as in progress to
+// JetStream every inProgressInterval, which should be well within the ack wait
+// of the consumer, so that they are not redelivered while they wait. A zero
+// interval disables it.
+func NewPool(logger *slog.Logger, registry prometheus.Registerer, processor Processor, workers int, queueSize int, inProgressInterval time.Duration) *Pool {
+	workers = max(workers, 1)
+
+	p := &Pool{
+		logger:             logger,
+		processor:          processor,
+		key:                RepositoryKey,
+		queues:             make([]chan transport.JetstreamMsg, workers),
+		inProgressInterval: inProgressInterval,
+		waiting:            map[transport.JetstreamMsg]struct{}{},
+		stopping:           make(chan struct{}),
+		stopHeartbeat:      make(chan struct{}),
+		queueDepth: promauto.With(registry).NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "adapter_worker_queue_depth",
+				Help: "Number of messages waiting in the queue of each adapter worker.",
+			}, []string{"worker"},
+		),
+		busyWorkers: promauto.With(registry).NewGauge(
+			prometheus.GaugeOpts{
+				Name: "adapter_workers_busy",
+				Help: "Number of adapter workers currently processing a message.",
+			},
+		),
+		busySeconds: promauto.With(registry).NewCounter(
+			prometheus.CounterOpts{
+				Name: "adapter_worker_busy_seconds_total",
+				Help: "Total time spent by all adapter workers processing messages.",
+			},
+		),
+	}
+
+	promauto.With(registry).NewGauge(
+		prometheus.GaugeOpts{
+			Name: "adapter_workers",
+			Help: "Number of adapter workers in the pool.",
+		},
+	).Set(float64(workers))
+
+	for i := range p.queues {
+		p.queues[i] = make(chan transport.JetstreamMsg, queueSize)
+	}
+
+	return p
+}
+
+// Start launches the workers.
+func (p *Pool) Start() {
+	for i, queue := range p.queues {
+		p.wg.Add(1)
+		go p.work(strconv.Itoa(i), queue)
+	}
+
+	if p.inProgressInterval > 0 {
+		p.heartbeat.Add(1)
+		go p.reportInProgress()
+	}
+}
+
+// Submit queues the message on the worker responsible for its ordering key,
+// blocking while that queue is full. Messages submitted after Stop are left
+// unacknowledged for JetStream to redeliver.
+func (p *Pool) Submit(msg transport.JetstreamMsg) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		p.logger.Warn("Worker pool is stopped, leaving message for redelivery", "subject", msg.Subject())
+		return
+	}
+
+	var i int
+	if key := p.key(msg); key != "" {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		i = int(h.Sum32() % uint32(len(p.queues)))
+	} else {
+		i = int(p.next.Add(1) % uint64(len(p.queues)))
+	}
+
+	p.waitingMu.Lock()
+	p.waiting[msg] = struct{}{}
+	p.waitingMu.Unlock()
+
+	p.queueDepth.WithLabelValues(strconv.Itoa(i)).Inc()
+	p.queues[i] <- msg
+}
+
+// Stop stops accepting new messages and waits for the workers to finish the
+// messages already queued. Messages waiting to be retried are handed back to
+// JetStream instead, along with the queued messages with the same ordering key.
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	p.stopped = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	close(p.stopping)
+	p.mu.Unlock()
+
+	p.wg.Wait()
+
+	close(p.stopHeartbeat)
+	p.heartbeat.Wait()
+}
+
+// reportInProgress tells JetStream that the messages waiting in the queues are
+// in progress, resetting their ack wait, until the pool is stopped.
+func (p *Pool) reportInProgress() {
+	defer p.heartbeat.Done()
+
+	ticker := time.NewTicker(p.inProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopHeartbeat:
+			return
+		case <-ticker.C:
+		}
+
+		p.waitingMu.Lock()
+		waiting := make([]transport.JetstreamMsg, 0, len(p.waiting))
+		for msg := range p.waiting {
+			waiting = append(waiting, msg)
+		}
+		p.waitingMu.Unlock()
+
+		for _, msg := range waiting {
+			if err := msg.InProgress(); err != nil {
+				p.logger.Warn("Failed to report queued message in progress", "subject", msg.Subject(), "error", err)
+			}
+		}
+	}
+}
+
+func (p *Pool) work(worker string, queue <-chan transport.JetstreamMsg) {
+	defer p.wg.Done()
+
+	// Back-offs of the messages handed back to JetStream while stopping, by
+	// ordering key, which later messages with the key are handed back with so
+	// that they are not redelivered before them.
+	handedBack := map[string]time.Duration{}
+
+	for msg := range queue {
+		p.waitingMu.Lock()
+		delete(p.waiting, msg)
+		p.waitingMu.Unlock()
+
+		p.queueDepth.WithLabelValues(worker).Dec()
+
+		key := p.key(msg)
+		if delay, found := handedBack[key]; found {
+			p.handBack(msg, delay)
+			continue
+		}
+
+		if delay, stopped := p.process(worker, msg); stopped && key != "" {
+			handedBack[key] = delay
+		}
+	}
+}
+
+// process processes the message, retrying it after the back-off for as long as
+// the processor negatively acknowledges it. Should the pool be stopped while
+// waiting to retry, the message is handed back to JetStream and its back-off is
+// returned.
+func (p *Pool) process(worker string, msg transport.JetstreamMsg) (time.Duration, bool) {
+	retrying := &retryMsg{JetstreamMsg: msg}
+	for {
+		retrying.naked = false
+
+		p.busyWorkers.Inc()
+		start := time.Now()
+
+		err := p.processor.Process(retrying)
+		if err != nil {
+			p.logger.Error("Failed to process message", "error", err.Error(), "worker", worker)
+		}
+
+		p.busySeconds.Add(time.Since(start).Seconds())
+		p.busyWorkers.Dec()
+
+		if !retrying.naked {
+			return 0, false
+		}
+
+		// The pull request that the push waits for is queued after it on the
+		// same worker, so waiting here would only have it wait in vain.
+		if errors.Is(err, translator.ErrMergeNotReported) {
+			p.handBack(msg, retrying.delay)
+			return 0, false
+		}
+
+		if !p.wait(msg, retrying.delay) {
+			p.handBack(msg, retrying.delay)
+			return retrying.delay, true
+		}
+		retrying.retries++
+	}
+}
+
+// wait waits for the back-off of a message to retry to pass, reporting it as in
+// progress meanwhile. It returns false if the pool is stopped before then.
+func (p *Pool) wait(msg transport.JetstreamMsg, delay time.Duration) bool {
+	p.waitingMu.Lock()
+	p.waiting[msg] = struct{}{}
+	p.waitingMu.Unlock()
+
+	defer func() {
+		p.waitingMu.Lock()
+		delete(p.waiting, msg)
+		p.waitingMu.Unlock()
+	}()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-p.stopping:
+		return false
+	}
+}
+
+// handBack negatively acknowledges the message for JetStream to redeliver it
+// after the delay.
+func (p *Pool) handBack(msg transport.JetstreamMsg, delay time.Duration) {
+	p.logger.Warn("Handing message back for redelivery", "subject", msg.Subject(), "delay", delay)
+	if err := msg.NakWithDelay(delay); err != nil {
+		p.logger.Error("Failed to hand message back for redelivery", "subject", msg.Subject(), "error", err)
+	}
+}
+
+// retryMsg is processed in place of a message so that its negative
+// acknowledgements are retried by the worker instead of by JetStream. The
+// retries are counted in its number of deliveries.
+type retryMsg struct {
+	transport.JetstreamMsg
+	retries uint64
+	naked   bool
+	delay   time.Duration
+}
+
+func (m *retryMsg) Metadata() (*natsjs.MsgMetadata, error) {
+	metadata, err := m.JetstreamMsg.Metadata()
+	if err != nil {
+		return nil, err
+	}
+	counted := *metadata
+	counted.NumDelivered += m.retries
+	return &counted, nil
+}
+
+func (m *retryMsg) Nak() error {
+	return m.NakWithDelay(0)
+}
+
+func (m *retryMsg) NakWithDelay(delay time.Duration) error {
+	m.naked = true
+	m.delay = delay
+	return nil
+}
+
+// RepositoryKey returns the full name of the repository that the webhook
+// payload concerns (the project path for GitLab), or an empty string when
+// there is none.
+func RepositoryKey(msg transport.JetstreamMsg) string {
+	var payload struct {
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+		Project struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"project"`
+	}
+	if err := json.Unmarshal(msg.Data(), &payload); err != nil {
+		return ""
+	}
+	if payload.Repository.FullName != "" {
+		return payload.Repository.FullName
+	}
+	return payload.Project.PathWithNamespace
+}
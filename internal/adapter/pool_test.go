@@ -0,0 +1,238 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/mocks"
+	"github.com/ansig/jetstream-cdevents-sink/internal/translator"
+	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingProcessor struct {
+	mu        sync.Mutex
+	processed map[string][]uint64
+}
+
+func (r *recordingProcessor) Process(msg transport.JetstreamMsg) error {
+	metadata, _ := msg.Metadata()
+
+	// Make later messages faster to process, so that they would overtake
+	// earlier ones if they were not handled in order.
+	time.Sleep(time.Duration(10-metadata.Sequence.Stream%10) * time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := RepositoryKey(msg)
+	r.processed[key] = append(r.processed[key], metadata.Sequence.Stream)
+	return nil
+}
+
+func TestPool(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	processor := &recordingProcessor{processed: map[string][]uint64{}}
+
+	pool := NewPool(logger, prometheus.NewRegistry(), processor, 4, 2, 0)
+	pool.Start()
+
+	repositories := []string{"yoloco/project1", "yoloco/project2", "yoloco/project3"}
+	expected := map[string][]uint64{}
+
+	for seq := uint64(1); seq <= 30; seq++ {
+		repository := repositories[seq%uint64(len(repositories))]
+		msg := mocks.NewJetstreamMsg("webhooks.gitea.push", []byte(fmt.Sprintf(`{"repository": {"full_name": "%s"}}`, repository)))
+		msg.StreamSeq = seq
+		expected[repository] = append(expected[repository], seq)
+		pool.Submit(msg)
+	}
+
+	pool.Stop()
+
+	assert.Equal(t, expected, processor.processed, "messages for each repository must be processed in arrival order")
+}
+
+type blockingProcessor struct {
+	release chan struct{}
+}
+
+func (b *blockingProcessor) Process(msg transport.JetstreamMsg) error {
+	<-b.release
+	return nil
+}
+
+func TestPoolReportsQueuedMessagesInProgress(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	processor := &blockingProcessor{release: make(chan struct{})}
+
+	pool := NewPool(logger, prometheus.NewRegistry(), processor, 1, 2, 10*time.Millisecond)
+	pool.Start()
+
+	processing := mocks.NewJetstreamMsg("webhooks.gitea.push", []byte(`{"repository": {"full_name": "yoloco/project1"}}`))
+	queued := mocks.NewJetstreamMsg("webhooks.gitea.push", []byte(`{"repository": {"full_name": "yoloco/project1"}}`))
+	pool.Submit(processing)
+	pool.Submit(queued)
+
+	assert.Eventually(t, func() bool { return queued.InProgressed.Load() >= 2 }, time.Second, 5*time.Millisecond, "queued message should be reported in progress while it waits")
+
+	close(processor.release)
+	pool.Stop()
+
+	reported := queued.InProgressed.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, reported, queued.InProgressed.Load(), "message should no longer be reported in progress once processed")
+	assert.Equal(t, int32(0), processing.InProgressed.Load(), "message being processed should not be reported in progress")
+}
+
+type delivery struct {
+	seq          uint64
+	numDelivered uint64
+}
+
+type retryingProcessor struct {
+	mu         sync.Mutex
+	naks       map[uint64]int
+	delay      time.Duration
+	err        error
+	deliveries []delivery
+}
+
+func (r *retryingProcessor) Process(msg transport.JetstreamMsg) error {
+	metadata, _ := msg.Metadata()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries = append(r.deliveries, delivery{metadata.Sequence.Stream, metadata.NumDelivered})
+	if r.naks[metadata.Sequence.Stream] > 0 {
+		r.naks[metadata.Sequence.Stream]--
+		return errors.Join(r.err, msg.NakWithDelay(r.delay))
+	}
+	return msg.Ack()
+}
+
+func (r *retryingProcessor) delivered() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+func newRepositoryMsg(repository string, seq uint64) *mocks.JetstreamMsg {
+	msg := mocks.NewJetstreamMsg("webhooks.gitea.push", []byte(fmt.Sprintf(`{"repository": {"full_name": "%s"}}`, repository)))
+	msg.StreamSeq = seq
+	msg.NumDelivered = 1
+	return msg
+}
+
+func TestPoolRetriesMessagesInOrder(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	processor := &retryingProcessor{
+		naks:  map[uint64]int{1: 2},
+		delay: 20 * time.Millisecond,
+		err:   translator.Temporary(errors.New("publishing failed")),
+	}
+
+	pool := NewPool(logger, prometheus.NewRegistry(), processor, 1, 2, 0)
+	pool.Start()
+
+	retried := newRepositoryMsg("yoloco/project1", 1)
+	later := newRepositoryMsg("yoloco/project1", 2)
+	pool.Submit(retried)
+	pool.Submit(later)
+
+	assert.Eventually(t, func() bool { return len(processor.delivered()) == 4 }, time.Second, 5*time.Millisecond)
+	pool.Stop()
+
+	assert.Equal(t, []delivery{{1, 1}, {1, 2}, {1, 3}, {2, 1}}, processor.delivered(), "retries should count as deliveries and come before later messages")
+	assert.False(t, retried.Naked, "retried message should not be handed back to JetStream")
+	assert.True(t, retried.Acked)
+	assert.True(t, later.Acked)
+}
+
+func TestPoolHandsBackMessagesAwaitingMerge(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	processor := &retryingProcessor{
+		naks:  map[uint64]int{1: 1},
+		delay: time.Minute,
+		err:   translator.Temporary(fmt.Errorf("%w: merge of 1234 in yoloco/project1", translator.ErrMergeNotReported)),
+	}
+
+	pool := NewPool(logger, prometheus.NewRegistry(), processor, 1, 2, 0)
+	pool.Start()
+
+	push := newRepositoryMsg("yoloco/project1", 1)
+	pullRequest := newRepositoryMsg("yoloco/project1", 2)
+	pool.Submit(push)
+	pool.Submit(pullRequest)
+	pool.Stop()
+
+	assert.Equal(t, []delivery{{1, 1}, {2, 1}}, processor.delivered(), "push awaiting merge should not hold back the pull request")
+	assert.True(t, push.Naked, "push awaiting merge should be handed back to JetStream")
+	assert.Equal(t, time.Minute, push.NakDelay)
+	assert.True(t, pullRequest.Acked)
+}
+
+func TestPoolHandsBackRetriesWhenStopped(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	processor := &retryingProcessor{
+		naks:  map[uint64]int{1: 1},
+		delay: time.Minute,
+		err:   translator.Temporary(errors.New("publishing failed")),
+	}
+
+	pool := NewPool(logger, prometheus.NewRegistry(), processor, 1, 3, 0)
+	pool.Start()
+
+	retried := newRepositoryMsg("yoloco/project1", 1)
+	later := newRepositoryMsg("yoloco/project1", 2)
+	other := newRepositoryMsg("yoloco/project2", 3)
+	pool.Submit(retried)
+	pool.Submit(later)
+	pool.Submit(other)
+
+	assert.Eventually(t, func() bool { return len(processor.delivered()) == 1 }, time.Second, 5*time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopping should not wait for the back-off of the retried message")
+	}
+
+	assert.Equal(t, []delivery{{1, 1}, {3, 1}}, processor.delivered(), "later message of the retried repository should not be processed")
+	assert.True(t, retried.Naked, "retried message should be handed back to JetStream")
+	assert.Equal(t, time.Minute, retried.NakDelay)
+	assert.True(t, later.Naked, "later message should be handed back to JetStream after the retried one")
+	assert.Equal(t, time.Minute, later.NakDelay)
+	assert.True(t, other.Acked)
+}
+
+func TestRepositoryKey(t *testing.T) {
+	for payload, expectedKey := range map[string]string{
+		`{"repository": {"full_name": "yoloco/project1"}}`:        "yoloco/project1",
+		`{"project": {"path_with_namespace": "yoloco/project2"}}`: "yoloco/project2",
+		`{"foo": "bar"}`: "",
+		`notjson`:        "",
+	} {
+		assert.Equal(t, expectedKey, RepositoryKey(mocks.NewJetstreamMsg("webhooks.gitea.push", []byte(payload))))
+	}
+}
@@ -0,0 +1,56 @@
+package correlation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
+
+	natsjs "github.com/nats-io/nats.go/jetstream"
+)
+
+type keyValueMergeRecorder struct {
+	kv transport.JetstreamKeyValue
+}
+
+// NewKeyValueMergeRecorder creates a recorder that keeps the merges reported by
+// pull requests in a JetStream key value bucket, where the key is the
+// repository and commit sha. How long merges are remembered is given by the TTL
+// of the bucket.
+func NewKeyValueMergeRecorder(kv transport.JetstreamKeyValue) *keyValueMergeRecorder {
+	return &keyValueMergeRecorder{kv: kv}
+}
+
+// Record marks the merge of the commit in the repository as reported by a pull
+// request. Recording the same merge again, e.g. when its webhook is
+// redelivered, has no further effect.
+func (r *keyValueMergeRecorder) Record(repository string, sha string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.kv.Put(ctx, mergeKey(repository, sha), []byte("pull_request"))
+	return err
+}
+
+// Recorded reports whether the merge of the commit in the repository has been
+// reported by a pull request.
+func (r *keyValueMergeRecorder) Recorded(repository string, sha string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := r.kv.Get(ctx, mergeKey(repository, sha))
+	if errors.Is(err, natsjs.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func mergeKey(repository string, sha string) string {
+	return fmt.Sprintf("%s/%s", repository, sha)
+}
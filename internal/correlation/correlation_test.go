@@ -0,0 +1,96 @@
+package correlation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/mocks"
+	natsjs "github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type keyValueEntry struct {
+	natsjs.KeyValueEntry
+}
+
+func TestKeyValueMergeRecorderRecord(t *testing.T) {
+
+	key := "yoloco/project1/9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"
+
+	for _, tc := range []struct {
+		title       string
+		putError    error
+		expectError bool
+	}{
+		{
+			title: "records merge",
+		},
+		{
+			title:       "error when put fails",
+			putError:    errors.New("connection closed"),
+			expectError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			mockKeyValue := &mocks.JetstreamKeyValue{}
+			mockKeyValue.On("Put", key, []byte("pull_request")).Return(uint64(1), tc.putError)
+
+			recorder := NewKeyValueMergeRecorder(mockKeyValue)
+			err := recorder.Record("yoloco/project1", "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2")
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			mockKeyValue.AssertCalled(t, "Put", key, []byte("pull_request"))
+		})
+	}
+}
+
+func TestKeyValueMergeRecorderRecorded(t *testing.T) {
+
+	key := "yoloco/project1/9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"
+
+	for _, tc := range []struct {
+		title            string
+		getError         error
+		expectedRecorded bool
+		expectError      bool
+	}{
+		{
+			title:            "merge reported by pull request",
+			expectedRecorded: true,
+		},
+		{
+			title:            "merge not reported",
+			getError:         natsjs.ErrKeyNotFound,
+			expectedRecorded: false,
+		},
+		{
+			title:       "error when get fails",
+			getError:    errors.New("connection closed"),
+			expectError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			mockKeyValue := &mocks.JetstreamKeyValue{}
+			if tc.getError != nil {
+				mockKeyValue.On("Get", key).Return(nil, tc.getError)
+			} else {
+				mockKeyValue.On("Get", key).Return(keyValueEntry{}, nil)
+			}
+
+			recorder := NewKeyValueMergeRecorder(mockKeyValue)
+			recorded, err := recorder.Recorded("yoloco/project1", "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2")
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRecorded, recorded)
+		})
+	}
+}
@@ -52,8 +52,8 @@ func (i *jetStreamInvalidMsgHandler) Receive(invalidMsg transport.JetstreamMsg,
 
 	var invalidMsgContent interface{}
 	if err := json.Unmarshal(invalidMsg.Data(), &invalidMsgContent); err != nil {
-		i.logger.Error("Invalid message handler failed to unmarchal message data", "error", err)
-		return err
+		// Messages that are invalid for not being JSON are kept as a string.
+		invalidMsgContent = string(invalidMsg.Data())
 	}
 
 	outgoingMsgData, err := json.Marshal(Holder{
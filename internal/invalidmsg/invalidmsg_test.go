@@ -62,3 +62,31 @@ func TestJetStreamInvalidMsgHandler(t *testing.T) {
 
 	mockPublisher.AssertCalled(t, "Publish", expectedOutgoingSubject, expectedOutgoingMsgData)
 }
+
+func TestJetStreamInvalidMsgHandlerNonJsonContent(t *testing.T) {
+
+	mockPublisher := &mocks.JetstreamPublisher{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	handler := NewJetStreamInvalidMsgHandler(logger, mockPublisher, "invalid")
+
+	invalidMsg := mocks.NewJetstreamMsg("webhooks.foo", []byte("notjson"))
+	invalidMsg.StreamSeq = 123
+	invalidMsg.NumDelivered = 1
+
+	mockPublisher.On("Publish", mock.Anything, mock.Anything).Return(&jetstream.PubAck{Stream: "mockStream"}, nil)
+
+	err := handler.Receive(invalidMsg, fmt.Errorf("Message payload is not valid JSON"))
+	require.NoError(t, err, "should not return an error")
+
+	expectedOutgoingMsgData, err := json.Marshal(Holder{
+		Subject:      "webhooks.foo",
+		Content:      "notjson",
+		StreamSeq:    123,
+		NumDelivered: 1,
+		Error:        "Message payload is not valid JSON",
+	})
+	require.NoError(t, err, "Failed to create expected message data")
+
+	mockPublisher.AssertCalled(t, "Publish", "invalid.webhooks.foo", expectedOutgoingMsgData)
+}
@@ -2,9 +2,12 @@ package mocks
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
+	"github.com/ansig/jetstream-cdevents-sink/internal/translator"
 	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 	"github.com/stretchr/testify/mock"
 
@@ -15,9 +18,12 @@ type CloudEventPublisher struct {
 	mock.Mock
 }
 
-func (m *CloudEventPublisher) Publish(cdEvent cdevents.CDEvent) error {
+func (m *CloudEventPublisher) Publish(ctx context.Context, cdEvent cdevents.CDEvent, opts ...transport.PublishOpt) (*jetstream.PubAck, error) {
 	args := m.Called(cdEvent)
-	return args.Error(0)
+	if args.Get(0) == nil {
+		return nil, args.Error(1) // Because otherwise we will panic on the type conversion below when first argument is nil
+	}
+	return args.Get(0).(*jetstream.PubAck), args.Error(1)
 }
 
 type JetstreamPublisher struct {
@@ -32,11 +38,24 @@ func (m *JetstreamPublisher) Publish(ctx context.Context, subject string, data [
 	return args.Get(0).(*jetstream.PubAck), args.Error(1)
 }
 
+func (m *JetstreamPublisher) PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error) {
+	args := m.Called(msg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1) // Because otherwise we will panic on the type conversion below when first argument is nil
+	}
+	return args.Get(0).(*jetstream.PubAck), args.Error(1)
+}
+
 type JetstreamMsg struct {
 	mock.Mock
 	subject      string
 	data         []byte
+	Header       nats.Header
 	Acked        bool
+	Naked        bool
+	Termed       bool
+	NakDelay     time.Duration
+	InProgressed atomic.Int32
 	ConsumerSeq  uint64
 	StreamSeq    uint64
 	NumDelivered uint64
@@ -45,10 +64,30 @@ type JetstreamMsg struct {
 
 func (m *JetstreamMsg) Subject() string { return m.subject }
 func (m *JetstreamMsg) Data() []byte    { return m.data }
+func (m *JetstreamMsg) Headers() nats.Header {
+	return m.Header
+}
 func (m *JetstreamMsg) Ack() error {
 	m.Acked = true
 	return nil
 }
+func (m *JetstreamMsg) Nak() error {
+	m.Naked = true
+	return nil
+}
+func (m *JetstreamMsg) NakWithDelay(delay time.Duration) error {
+	m.Naked = true
+	m.NakDelay = delay
+	return nil
+}
+func (m *JetstreamMsg) Term() error {
+	m.Termed = true
+	return nil
+}
+func (m *JetstreamMsg) InProgress() error {
+	m.InProgressed.Add(1)
+	return nil
+}
 func (m *JetstreamMsg) Metadata() (*jetstream.MsgMetadata, error) {
 	return &jetstream.MsgMetadata{
 		Sequence: jetstream.SequencePair{
@@ -71,12 +110,12 @@ type WebhookTranslator struct {
 	mock.Mock
 }
 
-func (m *WebhookTranslator) Translate(data []byte) (cdevents.CDEvent, error) {
-	args := m.Called(data)
+func (m *WebhookTranslator) Translate(req translator.Request) ([]cdevents.CDEvent, error) {
+	args := m.Called(req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1) // Because otherwise we will panic on the type conversion below when first argument is nil
 	}
-	return args.Get(0).(cdevents.CDEvent), args.Error(1)
+	return args.Get(0).([]cdevents.CDEvent), args.Error(1)
 }
 
 type InvalidMessageHandler struct {
@@ -87,3 +126,37 @@ func (m *InvalidMessageHandler) Receive(invalidMsg transport.JetstreamMsg, origi
 	args := m.Called(invalidMsg, originalErr)
 	return args.Error(0)
 }
+
+type JetstreamStream struct {
+	mock.Mock
+}
+
+func (m *JetstreamStream) GetMsg(ctx context.Context, seq uint64, opts ...jetstream.GetMsgOpt) (*jetstream.RawStreamMsg, error) {
+	args := m.Called(seq)
+	if args.Get(0) == nil {
+		return nil, args.Error(1) // Because otherwise we will panic on the type conversion below when first argument is nil
+	}
+	return args.Get(0).(*jetstream.RawStreamMsg), args.Error(1)
+}
+
+func (m *JetstreamStream) DeleteMsg(ctx context.Context, seq uint64) error {
+	args := m.Called(seq)
+	return args.Error(0)
+}
+
+type JetstreamKeyValue struct {
+	mock.Mock
+}
+
+func (m *JetstreamKeyValue) Put(ctx context.Context, key string, value []byte) (uint64, error) {
+	args := m.Called(key, value)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *JetstreamKeyValue) Get(ctx context.Context, key string) (jetstream.KeyValueEntry, error) {
+	args := m.Called(key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1) // Because otherwise we will panic on the type conversion below when first argument is nil
+	}
+	return args.Get(0).(jetstream.KeyValueEntry), args.Error(1)
+}
@@ -1,23 +1,29 @@
 package sink
 
 import (
+	"context"
 	"io"
 	"log/slog"
 	"mime"
 	"net/http"
+	"time"
 
-	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
-
+	"github.com/ansig/jetstream-cdevents-sink/internal/translator"
 	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
 )
 
 type sink struct {
-	logger *slog.Logger
+	logger      *slog.Logger
+	specVersion string
 }
 
-func New(logger *slog.Logger) *sink {
+// New creates a sink that accepts events of all spec versions supported by the
+// SDK and publishes them converted to the spec version, or as they are when it
+// is empty.
+func New(logger *slog.Logger, specVersion string) *sink {
 	return &sink{
-		logger: logger,
+		logger:      logger,
+		specVersion: specVersion,
 	}
 }
 
@@ -58,19 +64,33 @@ func (s *sink) Handler(cePublisher transport.CloudEventPublisher) http.Handler {
 			return
 		}
 
-		cdevent, err := cdeventsv04.NewFromJsonBytes(data)
+		cdevent, err := translator.ParseEvent(data)
 		if err != nil {
 			s.logger.Error("Sink failed to create CDEvent from payload", "error", err)
 			http.Error(w, "Payload is not a valid CDEvent", http.StatusBadRequest)
 			return
 		}
 
-		if err := cePublisher.Publish(cdevent); err != nil {
+		converted, err := translator.ConvertEvent(cdevent, s.specVersion)
+		if err != nil {
+			s.logger.Error("Sink failed to convert CDEvent", "type", cdevent.GetType(), "spec_version", s.specVersion, "error", err)
+			http.Error(w, "CDEvent cannot be converted to spec version "+s.specVersion, http.StatusBadRequest)
+			return
+		}
+		cdevent = converted
+
+		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+		defer cancel()
+
+		pubAck, err := cePublisher.Publish(ctx, cdevent, transport.WithIngestTime(time.Now()))
+		if err != nil {
 			s.logger.Error("Sink failed to publish CDEvent", "error", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
 
+		s.logger.Debug("Sink published CDEvent", "type", cdevent.GetType(), "stream", pubAck.Stream, "stream_seq", pubAck.Sequence)
+
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
@@ -10,7 +10,10 @@ import (
 	"testing"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/mocks"
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv03 "github.com/cdevents/sdk-go/pkg/api/v03"
 	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -18,41 +21,103 @@ import (
 
 func TestSinkHandler(t *testing.T) {
 
-	mockPublisher := &mocks.CloudEventPublisher{}
-	mockPublisher.On("Publish", mock.Anything).Return(nil)
-
 	testLogger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
-	changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+	changeMergedV04, err := cdeventsv04.NewChangeMergedEvent()
 	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedV04.SetSource("git.example.com")
+	changeMergedV04.SetSubjectId("42")
 
-	data, err := json.Marshal(changeMergedEvent)
-	require.NoError(t, err, "failed to unmarchal CDEvent for testing")
-
-	body := strings.NewReader(string(data))
-
-	req := httptest.NewRequest("POST", "/", body)
-
-	requestHeaders := map[string][]string{
-		"Content-Type": {"application/json"},
-	}
-
-	for k, v := range requestHeaders {
-		req.Header.Add(k, strings.Join(v, ","))
+	changeMergedV03, err := cdeventsv03.NewChangeMergedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedV03.SetSource("git.example.com")
+	changeMergedV03.SetSubjectId("42")
+
+	for _, tc := range []struct {
+		title               string
+		event               cdevents.CDEvent
+		body                string
+		specVersion         string
+		expectedStatus      int
+		expectedSpecVersion string
+		expectedType        cdevents.CDEventType
+	}{
+		{
+			title:               "publishes event of spec version 0.4.1",
+			event:               changeMergedV04,
+			expectedStatus:      http.StatusOK,
+			expectedSpecVersion: "0.4.1",
+			expectedType:        cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:               "publishes event of spec version 0.3.0",
+			event:               changeMergedV03,
+			expectedStatus:      http.StatusOK,
+			expectedSpecVersion: "0.3.0",
+			expectedType:        cdevents.ChangeMergedEventTypeV0_1_2,
+		},
+		{
+			title:               "publishes event converted to spec version 0.3.0",
+			event:               changeMergedV04,
+			specVersion:         "0.3.0",
+			expectedStatus:      http.StatusOK,
+			expectedSpecVersion: "0.3.0",
+			expectedType:        cdevents.ChangeMergedEventTypeV0_1_2,
+		},
+		{
+			title:               "publishes event converted to spec version 0.4.1",
+			event:               changeMergedV03,
+			specVersion:         "0.4.1",
+			expectedStatus:      http.StatusOK,
+			expectedSpecVersion: "0.4.1",
+			expectedType:        cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:          "rejects invalid event",
+			body:           `{"context": {"version": "0.4.1", "type": "dev.cdevents.change.merged.0.2.0"}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			title:          "rejects event of unsupported spec version",
+			body:           `{"context": {"version": "0.2.0", "type": "dev.cdevents.change.merged.0.1.2"}}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			mockPublisher := &mocks.CloudEventPublisher{}
+			mockPublisher.On("Publish", mock.Anything).Return(&jetstream.PubAck{Stream: "mockStream", Sequence: 1}, nil)
+
+			body := tc.body
+			if tc.event != nil {
+				data, err := json.Marshal(tc.event)
+				require.NoError(t, err, "failed to marshal CDEvent for testing")
+				body = string(data)
+			}
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			req.Header.Add("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+
+			sink := New(testLogger, tc.specVersion)
+			sink.Handler(mockPublisher).ServeHTTP(rec, req)
+
+			res := rec.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, res.StatusCode)
+
+			if tc.expectedStatus != http.StatusOK {
+				mockPublisher.AssertNotCalled(t, "Publish", mock.Anything)
+				return
+			}
+
+			responseBody, _ := io.ReadAll(res.Body)
+			assert.Equal(t, "OK", strings.TrimSpace(string(responseBody)), "Response body should be \"OK\"")
+
+			mockPublisher.AssertCalled(t, "Publish", mock.MatchedBy(func(cdEvent cdevents.CDEvent) bool {
+				return cdEvent.GetVersion() == tc.expectedSpecVersion && cdEvent.GetType() == tc.expectedType && cdEvent.GetSubjectId() == "42"
+			}))
+		})
 	}
-
-	rec := httptest.NewRecorder()
-
-	sink := New(testLogger)
-	sink.Handler(mockPublisher).ServeHTTP(rec, req)
-
-	res := rec.Result()
-	defer res.Body.Close()
-
-	assert.Equal(t, http.StatusOK, res.StatusCode)
-
-	responseBody, _ := io.ReadAll(res.Body)
-	assert.Equal(t, "OK", strings.TrimSpace(string(responseBody)), "Response body should be \"OK\"")
-
-	mockPublisher.AssertCalled(t, "Publish", mock.Anything)
 }
@@ -14,6 +14,8 @@ type GiteaPullRequestEvent struct {
 	Action      string      `json:"action"`
 	Number      int         `json:"number"`
 	PullRequest pullRequest `json:"pull_request"`
+	CommitId    string      `json:"commit_id"`
+	Review      *review     `json:"review"`
 	commonFields
 }
 
@@ -30,17 +32,79 @@ type GiteaDeleteEvent struct {
 	commonFields
 }
 
+type GiteaReleaseEvent struct {
+	Action  string  `json:"action"`
+	Release release `json:"release"`
+	commonFields
+}
+
+type GiteaPackageEvent struct {
+	Action  string          `json:"action"`
+	Package registryPackage `json:"package"`
+	Sender  user            `json:"sender"`
+}
+
+type GiteaWorkflowRunEvent struct {
+	Action      string      `json:"action"`
+	Workflow    workflow    `json:"workflow"`
+	WorkflowRun workflowRun `json:"workflow_run"`
+	commonFields
+}
+
+type GiteaWorkflowJobEvent struct {
+	Action      string      `json:"action"`
+	WorkflowJob workflowJob `json:"workflow_job"`
+	commonFields
+}
+
+type GiteaIssueEvent struct {
+	Action  string        `json:"action"`
+	Number  int           `json:"number"`
+	Issue   issue         `json:"issue"`
+	Comment *issueComment `json:"comment"`
+	IsPull  bool          `json:"is_pull"`
+	commonFields
+}
+
+type GiteaRepositoryEvent struct {
+	Action       string        `json:"action"`
+	Organization *organization `json:"organization"`
+	commonFields
+}
+
+type GiteaStatusEvent struct {
+	Id          int    `json:"id"`
+	Sha         string `json:"sha"`
+	Context     string `json:"context"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	TargetUrl   string `json:"target_url"`
+	Commit      commit `json:"commit"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+	commonFields
+}
+
 type commonFields struct {
 	Repository struct {
 		Name  string `json:"name"`
 		Owner struct {
 			Username string `json:"username"`
 		} `json:"owner"`
-		FullName string `json:"full_name"`
-		Url      string `json:"url"`
-		HtmlUrl  string `json:"html_url"`
-		SshUrl   string `json:"ssh_url"`
+		FullName      string `json:"full_name"`
+		Url           string `json:"url"`
+		HtmlUrl       string `json:"html_url"`
+		SshUrl        string `json:"ssh_url"`
+		CloneUrl      string `json:"clone_url"`
+		DefaultBranch string `json:"default_branch"`
 	} `json:"repository"`
+	Sender user `json:"sender"`
+}
+
+type user struct {
+	Id       int    `json:"id"`
+	Login    string `json:"login"`
+	Username string `json:"username"`
 }
 
 type authorCommitter struct {
@@ -58,13 +122,17 @@ type commit struct {
 }
 
 type pullRequest struct {
-	Id        int            `json:"id"`
-	Title     string         `json:"title"`
-	Base      pullRequestRef `json:"base"`
-	Head      pullRequestRef `json:"head"`
-	CreatedAt string         `json:"created_at"`
-	UpdatedAt string         `json:"updated_at"`
-	ClosedAt  string         `json:"closed_at"`
+	Id             int            `json:"id"`
+	Title          string         `json:"title"`
+	State          string         `json:"state"`
+	Merged         bool           `json:"merged"`
+	MergeCommitSha string         `json:"merge_commit_sha"`
+	Base           pullRequestRef `json:"base"`
+	Head           pullRequestRef `json:"head"`
+	CreatedAt      string         `json:"created_at"`
+	UpdatedAt      string         `json:"updated_at"`
+	ClosedAt       string         `json:"closed_at"`
+	MergedAt       string         `json:"merged_at"`
 }
 
 type pullRequestRef struct {
@@ -72,3 +140,149 @@ type pullRequestRef struct {
 	Ref   string `json:"ref"`
 	Sha   string `json:"sha"`
 }
+
+type review struct {
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+type release struct {
+	Id              int            `json:"id"`
+	TagName         string         `json:"tag_name"`
+	TargetCommitish string         `json:"target_commitish"`
+	Name            string         `json:"name"`
+	Body            string         `json:"body"`
+	Url             string         `json:"url"`
+	HtmlUrl         string         `json:"html_url"`
+	TarballUrl      string         `json:"tarball_url"`
+	ZipballUrl      string         `json:"zipball_url"`
+	Draft           bool           `json:"draft"`
+	Prerelease      bool           `json:"prerelease"`
+	CreatedAt       string         `json:"created_at"`
+	PublishedAt     string         `json:"published_at"`
+	Author          user           `json:"author"`
+	Assets          []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Id                 int    `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	DownloadCount      int    `json:"download_count"`
+	CreatedAt          string `json:"created_at"`
+	Uuid               string `json:"uuid"`
+	BrowserDownloadUrl string `json:"browser_download_url"`
+}
+
+type registryPackage struct {
+	Id        int    `json:"id"`
+	Owner     user   `json:"owner"`
+	Creator   user   `json:"creator"`
+	Type      string `json:"type"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	HtmlUrl   string `json:"html_url"`
+	CreatedAt string `json:"created_at"`
+}
+
+type workflow struct {
+	Id      int64  `json:"id"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	State   string `json:"state"`
+	HtmlUrl string `json:"html_url"`
+}
+
+type workflowRun struct {
+	Id           int64  `json:"id"`
+	Name         string `json:"name"`
+	DisplayTitle string `json:"display_title"`
+	Path         string `json:"path"`
+	Event        string `json:"event"`
+	HeadBranch   string `json:"head_branch"`
+	HeadSha      string `json:"head_sha"`
+	RunNumber    int64  `json:"run_number"`
+	RunAttempt   int64  `json:"run_attempt"`
+	Status       string `json:"status"`
+	Conclusion   string `json:"conclusion"`
+	Url          string `json:"url"`
+	HtmlUrl      string `json:"html_url"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+	RunStartedAt string `json:"run_started_at"`
+	CompletedAt  string `json:"completed_at"`
+}
+
+type workflowJob struct {
+	Id          int64          `json:"id"`
+	RunId       int64          `json:"run_id"`
+	RunUrl      string         `json:"run_url"`
+	Name        string         `json:"name"`
+	HeadBranch  string         `json:"head_branch"`
+	HeadSha     string         `json:"head_sha"`
+	RunAttempt  int64          `json:"run_attempt"`
+	Status      string         `json:"status"`
+	Conclusion  string         `json:"conclusion"`
+	Url         string         `json:"url"`
+	HtmlUrl     string         `json:"html_url"`
+	Labels      []string       `json:"labels"`
+	RunnerName  string         `json:"runner_name"`
+	CreatedAt   string         `json:"created_at"`
+	StartedAt   string         `json:"started_at"`
+	CompletedAt string         `json:"completed_at"`
+	Steps       []workflowStep `json:"steps"`
+}
+
+type workflowStep struct {
+	Name        string `json:"name"`
+	Number      int64  `json:"number"`
+	Status      string `json:"status"`
+	Conclusion  string `json:"conclusion"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+}
+
+type issue struct {
+	Id          int        `json:"id"`
+	Number      int        `json:"number"`
+	HtmlUrl     string     `json:"html_url"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	User        user       `json:"user"`
+	Labels      []label    `json:"labels"`
+	Milestone   *milestone `json:"milestone"`
+	Assignees   []user     `json:"assignees"`
+	State       string     `json:"state"`
+	PullRequest *struct {
+		Merged bool `json:"merged"`
+	} `json:"pull_request"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	ClosedAt  string `json:"closed_at"`
+}
+
+type issueComment struct {
+	Id        int    `json:"id"`
+	HtmlUrl   string `json:"html_url"`
+	User      user   `json:"user"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type label struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type milestone struct {
+	Id    int    `json:"id"`
+	Title string `json:"title"`
+	State string `json:"state"`
+}
+
+type organization struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
@@ -0,0 +1,92 @@
+package structs
+
+type GitHubPushEvent struct {
+	Ref        string         `json:"ref"`
+	Before     string         `json:"before"`
+	After      string         `json:"after"`
+	Created    bool           `json:"created"`
+	Deleted    bool           `json:"deleted"`
+	Forced     bool           `json:"forced"`
+	Commits    []githubCommit `json:"commits"`
+	HeadCommit githubCommit   `json:"head_commit"`
+	githubCommonFields
+}
+
+type GitHubPullRequestEvent struct {
+	Action      string            `json:"action"`
+	Number      int               `json:"number"`
+	PullRequest githubPullRequest `json:"pull_request"`
+	githubCommonFields
+}
+
+type GitHubCreateEvent struct {
+	Ref          string `json:"ref"`
+	RefType      string `json:"ref_type"`
+	MasterBranch string `json:"master_branch"`
+	githubCommonFields
+}
+
+type GitHubDeleteEvent struct {
+	Ref     string `json:"ref"`
+	RefType string `json:"ref_type"`
+	githubCommonFields
+}
+
+type githubCommonFields struct {
+	Repository struct {
+		Id    int64  `json:"id"`
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+		FullName      string `json:"full_name"`
+		Url           string `json:"url"`
+		HtmlUrl       string `json:"html_url"`
+		SshUrl        string `json:"ssh_url"`
+		DefaultBranch string `json:"default_branch"`
+	} `json:"repository"`
+	Sender githubUser `json:"sender"`
+}
+
+type githubUser struct {
+	Id    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+type githubAuthorCommitter struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+type githubCommit struct {
+	Id        string                `json:"id"`
+	Message   string                `json:"message"`
+	Timestamp string                `json:"timestamp"`
+	Url       string                `json:"url"`
+	Author    githubAuthorCommitter `json:"author"`
+	Committer githubAuthorCommitter `json:"committer"`
+}
+
+type githubPullRequest struct {
+	Id             int64                `json:"id"`
+	Number         int                  `json:"number"`
+	HtmlUrl        string               `json:"html_url"`
+	Title          string               `json:"title"`
+	State          string               `json:"state"`
+	Merged         bool                 `json:"merged"`
+	MergeCommitSha string               `json:"merge_commit_sha"`
+	User           githubUser           `json:"user"`
+	Base           githubPullRequestRef `json:"base"`
+	Head           githubPullRequestRef `json:"head"`
+	CreatedAt      string               `json:"created_at"`
+	UpdatedAt      string               `json:"updated_at"`
+	ClosedAt       string               `json:"closed_at"`
+	MergedAt       string               `json:"merged_at"`
+}
+
+type githubPullRequestRef struct {
+	Label string `json:"label"`
+	Ref   string `json:"ref"`
+	Sha   string `json:"sha"`
+}
@@ -0,0 +1,68 @@
+package structs
+
+type GitLabPushEvent struct {
+	ObjectKind        string         `json:"object_kind"`
+	EventName         string         `json:"event_name"`
+	Before            string         `json:"before"`
+	After             string         `json:"after"`
+	Ref               string         `json:"ref"`
+	CheckoutSha       string         `json:"checkout_sha"`
+	UserUsername      string         `json:"user_username"`
+	Commits           []gitlabCommit `json:"commits"`
+	TotalCommitsCount int            `json:"total_commits_count"`
+	gitlabCommonFields
+}
+
+type GitLabMergeRequestEvent struct {
+	ObjectKind       string             `json:"object_kind"`
+	EventType        string             `json:"event_type"`
+	User             gitlabUser         `json:"user"`
+	ObjectAttributes gitlabMergeRequest `json:"object_attributes"`
+	gitlabCommonFields
+}
+
+type gitlabCommonFields struct {
+	Project struct {
+		Id                int64  `json:"id"`
+		Name              string `json:"name"`
+		Namespace         string `json:"namespace"`
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebUrl            string `json:"web_url"`
+		GitSshUrl         string `json:"git_ssh_url"`
+		GitHttpUrl        string `json:"git_http_url"`
+		DefaultBranch     string `json:"default_branch"`
+	} `json:"project"`
+}
+
+type gitlabUser struct {
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+type gitlabCommit struct {
+	Id        string `json:"id"`
+	Message   string `json:"message"`
+	Title     string `json:"title"`
+	Timestamp string `json:"timestamp"`
+	Url       string `json:"url"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+type gitlabMergeRequest struct {
+	Id             int64        `json:"id"`
+	Iid            int          `json:"iid"`
+	Title          string       `json:"title"`
+	State          string       `json:"state"`
+	Action         string       `json:"action"`
+	SourceBranch   string       `json:"source_branch"`
+	TargetBranch   string       `json:"target_branch"`
+	MergeCommitSha string       `json:"merge_commit_sha"`
+	Url            string       `json:"url"`
+	LastCommit     gitlabCommit `json:"last_commit"`
+	CreatedAt      string       `json:"created_at"`
+	UpdatedAt      string       `json:"updated_at"`
+}
@@ -0,0 +1,63 @@
+package translator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const allRepositories = "*"
+
+// BranchPolicy decides which branches a push counts as a merge to. The
+// default branch of the repository always does.
+type BranchPolicy struct {
+	// Patterns holds glob patterns, as matched by path.Match, of further merge
+	// branches keyed by repository full name. Patterns under "*" apply to all
+	// repositories.
+	Patterns map[string][]string
+	// IgnoreOtherBranches makes pushes to other branches ignored instead of
+	// being translated into ChangeUpdated events.
+	IgnoreOtherBranches bool
+}
+
+// IsMergeBranch reports whether a push to the branch of the repository with
+// the given default branch should be considered a merge.
+func (b BranchPolicy) IsMergeBranch(repository string, defaultBranch string, branch string) bool {
+	if defaultBranch != "" && branch == defaultBranch {
+		return true
+	}
+
+	for _, patterns := range [][]string{b.Patterns[allRepositories], b.Patterns[repository]} {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, branch); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ParseBranchPatterns parses entries that are either a glob pattern applying
+// to all repositories, e.g. "release/*", or a pattern for a single repository
+// prefixed by its full name, e.g. "yoloco/project1:hotfix/*".
+func ParseBranchPatterns(entries []string) (map[string][]string, error) {
+	patterns := map[string][]string{}
+	for _, entry := range entries {
+		repository, pattern, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found {
+			repository, pattern = allRepositories, repository
+		}
+
+		if pattern == "" {
+			return nil, fmt.Errorf("empty branch pattern in: %q", entry)
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid branch pattern %q: %w", pattern, err)
+		}
+
+		patterns[repository] = append(patterns[repository], pattern)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,54 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBranchPatterns(t *testing.T) {
+
+	for _, tc := range []struct {
+		title            string
+		entries          []string
+		expectedPatterns map[string][]string
+		expectError      bool
+	}{
+		{
+			title:            "no entries",
+			entries:          nil,
+			expectedPatterns: map[string][]string{},
+		},
+		{
+			title:   "patterns for all and single repositories",
+			entries: []string{"release/*", " yoloco/project1:hotfix/* ", "yoloco/project1:develop"},
+			expectedPatterns: map[string][]string{
+				"*":               {"release/*"},
+				"yoloco/project1": {"hotfix/*", "develop"},
+			},
+		},
+		{
+			title:       "error on empty pattern",
+			entries:     []string{"yoloco/project1:"},
+			expectError: true,
+		},
+		{
+			title:       "error on malformed pattern",
+			entries:     []string{"release/["},
+			expectError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			patterns, err := ParseBranchPatterns(tc.entries)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPatterns, patterns)
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package translator
+
+import (
+	"embed"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/structs"
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+)
+
+// Kinds of custom data, identifying the webhook payload that events are
+// translated from. They are part of the published format of the events and
+// never change meaning: a change to a payload that removes, renames or retypes
+// a field gets a new version of its kind.
+const (
+	kindGiteaPush              = "gitea.push/v1"
+	kindGiteaPullRequest       = "gitea.pull_request/v1"
+	kindGiteaPullRequestReview = "gitea.pull_request_review/v1"
+	kindGiteaCreate            = "gitea.create/v1"
+	kindGiteaDelete            = "gitea.delete/v1"
+	kindGiteaRelease           = "gitea.release/v1"
+	kindGiteaPackage           = "gitea.package/v1"
+	kindGiteaWorkflowRun       = "gitea.workflow_run/v1"
+	kindGiteaWorkflowJob       = "gitea.workflow_job/v1"
+	kindGiteaIssues            = "gitea.issues/v1"
+	kindGiteaIssueComment      = "gitea.issue_comment/v1"
+	kindGiteaRepository        = "gitea.repository/v1"
+	kindGiteaStatus            = "gitea.status/v1"
+	kindGitHubPush             = "github.push/v1"
+	kindGitHubPullRequest      = "github.pull_request/v1"
+	kindGitHubCreate           = "github.create/v1"
+	kindGitHubDelete           = "github.delete/v1"
+	kindGitLabPush             = "gitlab.push/v1"
+	kindGitLabMergeRequest     = "gitlab.merge_request/v1"
+)
+
+// Schemas holds the JSON schema of the custom data of each kind, at
+// schemas/customdata/<kind>.json.
+//
+//go:embed schemas
+var Schemas embed.FS
+
+// customData is the custom data of events, holding the webhook payload that
+// they were translated from.
+type customData struct {
+	Kind    string
+	Content interface{}
+}
+
+// reviewCustomData is the custom data of ChangeReviewed events, which has the
+// reviewer and state of the review next to the payload.
+type reviewCustomData struct {
+	Kind        string
+	Reviewer    string
+	ReviewState string
+	Content     interface{}
+}
+
+// releaseCustomData is the custom data of events translated from releases,
+// which lists the release assets next to the payload.
+type releaseCustomData struct {
+	Kind    string
+	Assets  interface{}
+	Content interface{}
+}
+
+// customDataKinds has, for each kind, custom data holding an empty payload
+// from which the schema of the kind is generated.
+var customDataKinds = map[string]interface{}{
+	kindGiteaPush:              customData{Content: structs.GiteaPushEvent{}},
+	kindGiteaPullRequest:       customData{Content: structs.GiteaPullRequestEvent{}},
+	kindGiteaPullRequestReview: reviewCustomData{Content: structs.GiteaPullRequestEvent{}},
+	kindGiteaCreate:            customData{Content: structs.GiteaCreateEvent{}},
+	kindGiteaDelete:            customData{Content: structs.GiteaDeleteEvent{}},
+	kindGiteaRelease:           releaseCustomData{Assets: structs.GiteaReleaseEvent{}.Release.Assets, Content: structs.GiteaReleaseEvent{}},
+	kindGiteaPackage:           customData{Content: structs.GiteaPackageEvent{}},
+	kindGiteaWorkflowRun:       customData{Content: structs.GiteaWorkflowRunEvent{}},
+	kindGiteaWorkflowJob:       customData{Content: structs.GiteaWorkflowJobEvent{}},
+	kindGiteaIssues:            customData{Content: structs.GiteaIssueEvent{}},
+	kindGiteaIssueComment:      customData{Content: structs.GiteaIssueEvent{}},
+	kindGiteaRepository:        customData{Content: structs.GiteaRepositoryEvent{}},
+	kindGiteaStatus:            customData{Content: structs.GiteaStatusEvent{}},
+	kindGitHubPush:             customData{Content: structs.GitHubPushEvent{}},
+	kindGitHubPullRequest:      customData{Content: structs.GitHubPullRequestEvent{}},
+	kindGitHubCreate:           customData{Content: structs.GitHubCreateEvent{}},
+	kindGitHubDelete:           customData{Content: structs.GitHubDeleteEvent{}},
+	kindGitLabPush:             customData{Content: structs.GitLabPushEvent{}},
+	kindGitLabMergeRequest:     customData{Content: structs.GitLabMergeRequestEvent{}},
+}
+
+// setCustomData sets the custom data of the event, which is always JSON as
+// that is the only content type the CDEvents SDK decodes custom data from.
+func setCustomData(data interface{}, cdEvent cdevents.CDEvent) error {
+	return cdEvent.SetCustomData("application/json", data)
+}
+
+// CustomDataSchemaPath returns the path at which the schema of the custom data
+// of the event is served, or an empty string when the event has no custom data
+// of a known kind, e.g. when it comes from a mapping file.
+func CustomDataSchemaPath(cdEvent cdevents.CDEvent) string {
+	raw, err := cdEvent.GetCustomDataRaw()
+	if err != nil || len(raw) == 0 {
+		return ""
+	}
+	var data struct{ Kind interface{} }
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ""
+	}
+	kind, ok := data.Kind.(string)
+	if !ok {
+		return ""
+	}
+	if _, known := customDataKinds[kind]; !known {
+		return ""
+	}
+	return "/schemas/customdata/" + kind + ".json"
+}
+
+func addWebhookEventAsCustomData(kind string, webhookEvent interface{}, cdEvent cdevents.CDEvent) error {
+	return setCustomData(customData{Kind: kind, Content: webhookEvent}, cdEvent)
+}
+
+// customDataSchema generates the JSON schema of the custom data of the kind
+// from the Go types that it is marshalled from. Fields of the payload are not
+// required, so that adding fields to a payload keeps the kind compatible.
+func customDataSchema(kind string) map[string]interface{} {
+	data := reflect.ValueOf(customDataKinds[kind])
+
+	properties := map[string]interface{}{}
+	for i := 0; i < data.NumField(); i++ {
+		field := data.Field(i)
+		if field.Kind() == reflect.Interface && !field.IsNil() {
+			field = field.Elem()
+		}
+		properties[data.Type().Field(i).Name] = typeSchema(field.Type())
+	}
+	properties["Kind"] = map[string]interface{}{"const": kind}
+	// Set by custom data policies with a size limit.
+	properties["Trimmed"] = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+
+	return map[string]interface{}{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      kind,
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"Kind", "Content"},
+	}
+}
+
+// typeSchema returns the schema of the JSON that values of the type are
+// marshalled into.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		addFieldSchemas(t, properties)
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case reflect.Pointer:
+		return map[string]interface{}{"anyOf": []interface{}{typeSchema(t.Elem()), map[string]interface{}{"type": "null"}}}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// addFieldSchemas adds the schemas of the marshalled fields of the struct type
+// to the properties, including those of embedded structs.
+func addFieldSchemas(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addFieldSchemas(field.Type, properties)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type)
+	}
+}
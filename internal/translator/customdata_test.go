@@ -0,0 +1,107 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateSchemas = flag.Bool("update-schemas", false, "write the generated custom data schemas to the schemas directory")
+
+func TestCustomDataSchemas(t *testing.T) {
+
+	for kind := range customDataKinds {
+		t.Run(kind, func(t *testing.T) {
+			generated, err := json.MarshalIndent(customDataSchema(kind), "", "  ")
+			require.NoError(t, err, "schema should be marshalled")
+			generated = append(generated, '\n')
+
+			path := filepath.Join("schemas", "customdata", kind+".json")
+
+			if *updateSchemas {
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, generated, 0644))
+			}
+
+			published, err := Schemas.ReadFile(filepath.ToSlash(path))
+			require.NoError(t, err, "schema of %s should be published, run the tests with -update-schemas to generate it", kind)
+			assert.Equal(t, string(generated), string(published), "published schema of %s is out of date, bump the version of the kind unless the change is compatible and run the tests with -update-schemas", kind)
+		})
+	}
+
+	err := fs.WalkDir(Schemas, "schemas/customdata", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel("schemas/customdata", path)
+		kind := rel[:len(rel)-len(".json")]
+		assert.Contains(t, customDataKinds, kind, "published schema %s has no kind", path)
+		return nil
+	})
+	require.NoError(t, err, "schemas should be listed")
+}
+
+func TestCustomDataMatchesSchema(t *testing.T) {
+
+	for _, tc := range []struct {
+		example    string
+		translator Webhook
+		kind       string
+	}{
+		{"gitea/push_commit_main.json", &GiteaPush{}, kindGiteaPush},
+		{"gitea/push_tag.json", &GiteaPush{}, kindGiteaPush},
+		{"gitea/pull_request_closed.json", &GiteaPullRequest{}, kindGiteaPullRequest},
+		{"gitea/pull_request_review_approved.json", &GiteaPullRequestReview{}, kindGiteaPullRequestReview},
+		{"gitea/branch_created.json", &GiteaCreate{}, kindGiteaCreate},
+		{"gitea/branch_deleted.json", &GiteaDelete{}, kindGiteaDelete},
+		{"gitea/release_published.json", &GiteaRelease{}, kindGiteaRelease},
+		{"gitea/package_created_container.json", &GiteaPackage{}, kindGiteaPackage},
+		{"gitea/workflow_run_completed.json", &GiteaWorkflowRun{}, kindGiteaWorkflowRun},
+		{"gitea/workflow_job_completed.json", &GiteaWorkflowJob{}, kindGiteaWorkflowJob},
+		{"gitea/issue_closed.json", &GiteaIssues{}, kindGiteaIssues},
+		{"gitea/issue_comment_created.json", &GiteaIssueComment{}, kindGiteaIssueComment},
+		{"gitea/repository_created.json", &GiteaRepository{}, kindGiteaRepository},
+		{"gitea/status_failure.json", &GiteaStatus{}, kindGiteaStatus},
+		{"github/push_commit_main.json", &GitHubPush{}, kindGitHubPush},
+		{"github/pull_request_merged.json", &GitHubPullRequest{}, kindGitHubPullRequest},
+		{"github/branch_created.json", &GitHubCreate{}, kindGitHubCreate},
+		{"github/branch_deleted.json", &GitHubDelete{}, kindGitHubDelete},
+		{"gitlab/push_commit_main.json", &GitLabPush{}, kindGitLabPush},
+		{"gitlab/merge_request_merged.json", &GitLabMergeRequest{}, kindGitLabMergeRequest},
+	} {
+		t.Run(tc.example, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("..", "..", "examples", "webhooks", tc.example))
+			require.NoError(t, err, "example payload should be read")
+
+			cdEvents, err := tc.translator.Translate(Request{Payload: payload})
+			require.NoError(t, err, "example payload should be translated")
+			cdEvent := singleEvent(t, cdEvents)
+			assert.Equal(t, "application/json", cdEvent.GetCustomDataContentType(), "custom data content type")
+
+			customData, err := cdEvent.GetCustomDataRaw()
+			require.NoError(t, err, "custom data should be marshalled")
+
+			published, err := Schemas.ReadFile("schemas/customdata/" + tc.kind + ".json")
+			require.NoError(t, err, "schema of %s should be published", tc.kind)
+
+			schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(published))
+			require.NoError(t, err, "schema should be parsed")
+			compiler := jsonschema.NewCompiler()
+			require.NoError(t, compiler.AddResource("schema.json", schemaDoc))
+			schema, err := compiler.Compile("schema.json")
+			require.NoError(t, err, "schema should be compiled")
+
+			instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(customData))
+			require.NoError(t, err, "custom data should be parsed")
+			assert.NoError(t, schema.Validate(instance), "custom data should match the schema of %s", tc.kind)
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+)
+
+// Modes of embedding the webhook payload as custom data.
+const (
+	CustomDataFull      = "full"
+	CustomDataAllowlist = "allowlist"
+	CustomDataRedact    = "redact"
+	CustomDataNone      = "none"
+)
+
+// CustomDataPolicy decides how much of the webhook payload is embedded in the
+// custom data of translated events: all of it, only the allowlisted paths, all
+// but the redacted paths or none of it.
+//
+// Paths are dot separated field names within the payload, where "*" matches
+// any field or array element and "**" any number of them, e.g.
+// "pull_request.title" or "**.email". Fields that translators add next to the
+// payload are matched by their name, e.g. "Assets.*.name", and custom data
+// without a kind, such as that of mapping files, is matched from its root.
+// When the custom data is larger than MaxSize bytes, the largest fields of the
+// payload are trimmed until it fits and listed under Trimmed in the custom
+// data.
+type CustomDataPolicy struct {
+	Mode    string
+	Paths   []string
+	MaxSize int
+}
+
+// CustomDataPolicies holds the custom data policy of each translator, with the
+// policy under "*" applying to translators without a policy of their own.
+type CustomDataPolicies map[string]CustomDataPolicy
+
+// For returns the custom data policy of the translator.
+func (c CustomDataPolicies) For(name string) CustomDataPolicy {
+	if policy, exists := c[name]; exists {
+		return policy
+	}
+	return c["*"]
+}
+
+// ParseCustomDataPolicies parses entries of a translator name, or "*" for all
+// translators, and a mode separated by an equals sign, with paths separated by
+// "|" after a colon for the allowlist and redact modes, e.g.
+// "gitea.pull_request=allowlist:action|pull_request.title". The size limit
+// applies to all policies.
+func ParseCustomDataPolicies(entries []string, maxSize int) (CustomDataPolicies, error) {
+	policies := CustomDataPolicies{"*": {Mode: CustomDataFull, MaxSize: maxSize}}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		name, rule, found := strings.Cut(entry, "=")
+		if !found || name == "" || rule == "" {
+			return nil, fmt.Errorf("missing translator or mode in: %q", entry)
+		}
+
+		mode, rawPaths, _ := strings.Cut(rule, ":")
+		policy := CustomDataPolicy{Mode: mode, MaxSize: maxSize}
+		if rawPaths != "" {
+			policy.Paths = strings.Split(rawPaths, "|")
+		}
+
+		switch policy.Mode {
+		case CustomDataFull, CustomDataNone:
+			if len(policy.Paths) > 0 {
+				return nil, fmt.Errorf("custom data mode %q takes no paths in: %q", policy.Mode, entry)
+			}
+		case CustomDataAllowlist, CustomDataRedact:
+			if len(policy.Paths) == 0 {
+				return nil, fmt.Errorf("custom data mode %q requires paths in: %q", policy.Mode, entry)
+			}
+		default:
+			return nil, fmt.Errorf("unknown custom data mode %q in: %q", policy.Mode, entry)
+		}
+
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// Apply embeds the part of the payload in the custom data of the event that
+// the policy allows.
+func (p CustomDataPolicy) Apply(cdEvent cdevents.CDEvent) error {
+	switch {
+	case p.Mode == CustomDataNone:
+		return cdEvent.SetCustomData("", nil)
+	case p.Mode != CustomDataAllowlist && p.Mode != CustomDataRedact && p.MaxSize <= 0:
+		return nil
+	}
+
+	raw, err := cdEvent.GetCustomDataRaw()
+	if err != nil {
+		return err
+	}
+
+	var data map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil || data == nil {
+		// Only custom data that is an object is subject to the policy, which
+		// it always is except for what mapping files may set.
+		return nil
+	}
+
+	patterns := make([][]string, 0, len(p.Paths))
+	for _, path := range p.Paths {
+		patterns = append(patterns, strings.Split(path, "."))
+	}
+
+	if p.Mode == CustomDataAllowlist || p.Mode == CustomDataRedact {
+		if _, hasKind := data["Kind"].(string); hasKind {
+			content, _ := data["Content"].(map[string]interface{})
+			fields := map[string]interface{}{}
+			for key, value := range data {
+				if key != "Kind" && key != "Content" {
+					fields[key] = value
+				}
+			}
+			fields = p.filterPaths(fields, patterns)
+			fields["Kind"] = data["Kind"]
+			// The payload is required by the schemas of the kinds, so it is kept
+			// as an empty object when none of it is.
+			fields["Content"] = p.filterPaths(content, patterns)
+			data = fields
+		} else {
+			data = p.filterPaths(data, patterns)
+		}
+	}
+
+	if p.MaxSize > 0 {
+		if err := trimCustomData(data, p.MaxSize); err != nil {
+			return err
+		}
+	}
+
+	return setCustomData(data, cdEvent)
+}
+
+// filterPaths returns the parts of the object that the allowlist or redact mode
+// of the policy keeps, which is an empty object if there are none.
+func (p CustomDataPolicy) filterPaths(object map[string]interface{}, patterns [][]string) map[string]interface{} {
+	var kept interface{}
+	var ok bool
+	if p.Mode == CustomDataAllowlist {
+		kept, ok = allowPaths(object, patterns)
+	} else {
+		kept, ok = redactPaths(object, patterns)
+	}
+
+	if keptObject, isObject := kept.(map[string]interface{}); ok && isObject {
+		return keptObject
+	}
+	return map[string]interface{}{}
+}
+
+// WithCustomDataPolicy applies the custom data policy to the events translated
+// by the translator.
+func WithCustomDataPolicy(webhook Webhook, policy CustomDataPolicy) Webhook {
+	return &customDataPolicyWebhook{webhook: webhook, policy: policy}
+}
+
+type customDataPolicyWebhook struct {
+	webhook Webhook
+	policy  CustomDataPolicy
+}
+
+func (c *customDataPolicyWebhook) Translate(req Request) ([]cdevents.CDEvent, error) {
+	cdEvents, err := c.webhook.Translate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cdEvent := range cdEvents {
+		if err := c.policy.Apply(cdEvent); err != nil {
+			return nil, fmt.Errorf("failed to apply custom data policy: %w", err)
+		}
+	}
+
+	return cdEvents, nil
+}
+
+// allowPaths returns the parts of the value at paths matching the patterns,
+// or false if there are none.
+func allowPaths(value interface{}, patterns [][]string) (interface{}, bool) {
+	patterns = expandPatterns(patterns)
+	for _, pattern := range patterns {
+		if len(pattern) == 0 {
+			return value, true
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		kept := map[string]interface{}{}
+		for key, child := range v {
+			if keptChild, ok := allowPaths(child, childPatterns(patterns, key)); ok {
+				kept[key] = keptChild
+			}
+		}
+		return kept, len(kept) > 0
+	case []interface{}:
+		kept := []interface{}{}
+		for i, child := range v {
+			if keptChild, ok := allowPaths(child, childPatterns(patterns, strconv.Itoa(i))); ok {
+				kept = append(kept, keptChild)
+			}
+		}
+		return kept, len(kept) > 0
+	default:
+		return nil, false
+	}
+}
+
+// redactPaths returns the value without the parts at paths matching the
+// patterns, or false if the value itself matches.
+func redactPaths(value interface{}, patterns [][]string) (interface{}, bool) {
+	patterns = expandPatterns(patterns)
+	if len(patterns) == 0 {
+		return value, true
+	}
+	for _, pattern := range patterns {
+		if len(pattern) == 0 {
+			return nil, false
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		kept := map[string]interface{}{}
+		for key, child := range v {
+			if keptChild, ok := redactPaths(child, childPatterns(patterns, key)); ok {
+				kept[key] = keptChild
+			}
+		}
+		return kept, true
+	case []interface{}:
+		kept := make([]interface{}, 0, len(v))
+		for i, child := range v {
+			if keptChild, ok := redactPaths(child, childPatterns(patterns, strconv.Itoa(i))); ok {
+				kept = append(kept, keptChild)
+			}
+		}
+		return kept, true
+	default:
+		return value, true
+	}
+}
+
+// expandPatterns adds the patterns resulting from "**" matching no fields.
+func expandPatterns(patterns [][]string) [][]string {
+	expanded := make([][]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		for {
+			expanded = append(expanded, pattern)
+			if len(pattern) == 0 || pattern[0] != "**" {
+				break
+			}
+			pattern = pattern[1:]
+		}
+	}
+	return expanded
+}
+
+// childPatterns returns what remains of the patterns to match below the field
+// or array element with the key.
+func childPatterns(patterns [][]string, key string) [][]string {
+	var remaining [][]string
+	for _, pattern := range patterns {
+		if len(pattern) == 0 {
+			continue
+		}
+		switch pattern[0] {
+		case "**":
+			remaining = append(remaining, pattern)
+		case "*", key:
+			remaining = append(remaining, pattern[1:])
+		}
+	}
+	return remaining
+}
+
+// trimCustomData removes the largest fields of the payload, and then of the
+// custom data itself, until the custom data is no larger than maxSize bytes
+// when marshalled. The kind is always kept, as is the payload as an empty
+// object since the schemas of the kinds require it.
+func trimCustomData(data map[string]interface{}, maxSize int) error {
+	size, err := marshalledSize(data)
+	if err != nil || size <= maxSize {
+		return err
+	}
+
+	var trimmed []string
+	for _, parent := range []string{"Content", ""} {
+		fields := data
+		if parent != "" {
+			content, ok := data[parent].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			fields = content
+		}
+
+		keys := make([]string, 0, len(fields))
+		sizes := map[string]int{}
+		for key, value := range fields {
+			if parent == "" && (key == "Kind" || key == "Trimmed") {
+				continue
+			}
+			if _, isObject := value.(map[string]interface{}); parent == "" && key == "Content" && isObject {
+				continue
+			}
+			if sizes[key], err = marshalledSize(value); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if sizes[keys[i]] != sizes[keys[j]] {
+				return sizes[keys[i]] > sizes[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+
+		for _, key := range keys {
+			delete(fields, key)
+			if parent != "" {
+				trimmed = append(trimmed, parent+"."+key)
+			} else {
+				trimmed = append(trimmed, key)
+			}
+			data["Trimmed"] = trimmed
+
+			if size, err = marshalledSize(data); err != nil || size <= maxSize {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func marshalledSize(value interface{}) (int, error) {
+	marshalled, err := json.Marshal(value)
+	return len(marshalled), err
+}
@@ -0,0 +1,202 @@
+package translator
+
+import (
+	"encoding/json"
+	"testing"
+
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCustomDataPolicies(t *testing.T) {
+
+	for _, tc := range []struct {
+		title            string
+		entries          []string
+		expectedPolicies CustomDataPolicies
+		expectedError    bool
+	}{
+		{
+			title:            "embeds full payload by default",
+			expectedPolicies: CustomDataPolicies{"*": {Mode: CustomDataFull, MaxSize: 1024}},
+		},
+		{
+			title:   "parses policies for all and single translators",
+			entries: []string{"*=redact:**.email|**.avatar_url", "gitea.pull_request=allowlist:action|pull_request.title", "gitea.push=none"},
+			expectedPolicies: CustomDataPolicies{
+				"*":                  {Mode: CustomDataRedact, Paths: []string{"**.email", "**.avatar_url"}, MaxSize: 1024},
+				"gitea.pull_request": {Mode: CustomDataAllowlist, Paths: []string{"action", "pull_request.title"}, MaxSize: 1024},
+				"gitea.push":         {Mode: CustomDataNone, MaxSize: 1024},
+			},
+		},
+		{
+			title:         "error on missing mode",
+			entries:       []string{"gitea.push"},
+			expectedError: true,
+		},
+		{
+			title:         "error on unknown mode",
+			entries:       []string{"gitea.push=some"},
+			expectedError: true,
+		},
+		{
+			title:         "error on allowlist without paths",
+			entries:       []string{"gitea.push=allowlist"},
+			expectedError: true,
+		},
+		{
+			title:         "error on paths for mode without paths",
+			entries:       []string{"gitea.push=none:ref"},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			policies, err := ParseCustomDataPolicies(tc.entries, 1024)
+			if tc.expectedError {
+				assert.Error(t, err, "error should be returned")
+				return
+			}
+			require.NoError(t, err, "no error should be returned")
+			assert.Equal(t, tc.expectedPolicies, policies)
+		})
+	}
+}
+
+func TestCustomDataPoliciesFor(t *testing.T) {
+
+	policies := CustomDataPolicies{
+		"*":          {Mode: CustomDataFull},
+		"gitea.push": {Mode: CustomDataNone},
+	}
+
+	assert.Equal(t, CustomDataNone, policies.For("gitea.push").Mode, "policy of translator")
+	assert.Equal(t, CustomDataFull, policies.For("gitea.create").Mode, "policy for all translators")
+}
+
+func TestCustomDataPolicyApply(t *testing.T) {
+
+	payload := map[string]interface{}{
+		"action": "closed",
+		"number": 42,
+		"pull_request": map[string]interface{}{
+			"title": "Add foo",
+			"user":  map[string]interface{}{"login": "alice", "email": "alice@example.com", "avatar_url": "http://git.example.com/avatars/1"},
+			"assignees": []interface{}{
+				map[string]interface{}{"login": "bob", "email": "bob@example.com"},
+			},
+		},
+		"sender": map[string]interface{}{"login": "alice", "email": "alice@example.com"},
+	}
+
+	reviewData := reviewCustomData{
+		Kind:        kindGiteaPullRequestReview,
+		Reviewer:    "bob",
+		ReviewState: "approved",
+		Content:     payload,
+	}
+
+	releaseData := releaseCustomData{
+		Kind: kindGiteaRelease,
+		Assets: []interface{}{
+			map[string]interface{}{"name": "foo.tar.gz", "uploader": map[string]interface{}{"login": "alice", "email": "alice@example.com"}},
+		},
+		Content: map[string]interface{}{"action": "published"},
+	}
+
+	mappingData := map[string]interface{}{
+		"title":  "Add foo",
+		"author": map[string]interface{}{"login": "alice", "email": "alice@example.com"},
+	}
+
+	for _, tc := range []struct {
+		title              string
+		customData         interface{}
+		policy             CustomDataPolicy
+		expectedCustomData string
+	}{
+		{
+			title:              "embeds full payload",
+			policy:             CustomDataPolicy{Mode: CustomDataFull},
+			expectedCustomData: `{"Kind": "gitea.pull_request/v1", "Content": {"action": "closed", "number": 42, "pull_request": {"title": "Add foo", "user": {"login": "alice", "email": "alice@example.com", "avatar_url": "http://git.example.com/avatars/1"}, "assignees": [{"login": "bob", "email": "bob@example.com"}]}, "sender": {"login": "alice", "email": "alice@example.com"}}}`,
+		},
+		{
+			title:              "embeds allowlisted paths",
+			policy:             CustomDataPolicy{Mode: CustomDataAllowlist, Paths: []string{"action", "pull_request.title", "pull_request.assignees.*.login"}},
+			expectedCustomData: `{"Kind": "gitea.pull_request/v1", "Content": {"action": "closed", "pull_request": {"title": "Add foo", "assignees": [{"login": "bob"}]}}}`,
+		},
+		{
+			title:              "embeds empty payload when no paths are allowlisted",
+			policy:             CustomDataPolicy{Mode: CustomDataAllowlist, Paths: []string{"ref"}},
+			expectedCustomData: `{"Kind": "gitea.pull_request/v1", "Content": {}}`,
+		},
+		{
+			title:              "redacts paths",
+			policy:             CustomDataPolicy{Mode: CustomDataRedact, Paths: []string{"**.email", "pull_request.user.avatar_url"}},
+			expectedCustomData: `{"Kind": "gitea.pull_request/v1", "Content": {"action": "closed", "number": 42, "pull_request": {"title": "Add foo", "user": {"login": "alice"}, "assignees": [{"login": "bob"}]}, "sender": {"login": "alice"}}}`,
+		},
+		{
+			title:              "trims largest fields when too large",
+			policy:             CustomDataPolicy{Mode: CustomDataFull, MaxSize: 180},
+			expectedCustomData: `{"Kind": "gitea.pull_request/v1", "Content": {"action": "closed", "number": 42, "sender": {"login": "alice", "email": "alice@example.com"}}, "Trimmed": ["Content.pull_request"]}`,
+		},
+		{
+			title:              "keeps empty payload when trimmed entirely",
+			policy:             CustomDataPolicy{Mode: CustomDataFull, MaxSize: 40},
+			expectedCustomData: `{"Kind": "gitea.pull_request/v1", "Content": {}, "Trimmed": ["Content.pull_request", "Content.sender", "Content.action", "Content.number"]}`,
+		},
+		{
+			title:              "embeds fields next to payload only when allowlisted",
+			customData:         reviewData,
+			policy:             CustomDataPolicy{Mode: CustomDataAllowlist, Paths: []string{"action", "ReviewState"}},
+			expectedCustomData: `{"Kind": "gitea.pull_request_review/v1", "ReviewState": "approved", "Content": {"action": "closed"}}`,
+		},
+		{
+			title:              "redacts paths in fields next to payload",
+			customData:         releaseData,
+			policy:             CustomDataPolicy{Mode: CustomDataRedact, Paths: []string{"**.email"}},
+			expectedCustomData: `{"Kind": "gitea.release/v1", "Assets": [{"name": "foo.tar.gz", "uploader": {"login": "alice"}}], "Content": {"action": "published"}}`,
+		},
+		{
+			title:              "embeds allowlisted paths of custom data without kind",
+			customData:         mappingData,
+			policy:             CustomDataPolicy{Mode: CustomDataAllowlist, Paths: []string{"title", "author.login"}},
+			expectedCustomData: `{"title": "Add foo", "author": {"login": "alice"}}`,
+		},
+		{
+			title:              "redacts paths of custom data without kind",
+			customData:         mappingData,
+			policy:             CustomDataPolicy{Mode: CustomDataRedact, Paths: []string{"**.email"}},
+			expectedCustomData: `{"title": "Add foo", "author": {"login": "alice"}}`,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvent, err := cdeventsv04.NewChangeMergedEvent()
+			require.NoError(t, err, "unable to create CDEvent for tests")
+			if tc.customData != nil {
+				require.NoError(t, setCustomData(tc.customData, cdEvent))
+			} else {
+				require.NoError(t, addWebhookEventAsCustomData(kindGiteaPullRequest, payload, cdEvent))
+			}
+
+			require.NoError(t, tc.policy.Apply(cdEvent), "no error should be returned")
+
+			customData, err := cdEvent.GetCustomDataRaw()
+			require.NoError(t, err, "custom data should be marshalled")
+			assert.JSONEq(t, tc.expectedCustomData, string(customData))
+			assert.Equal(t, "application/json", cdEvent.GetCustomDataContentType(), "custom data content type")
+		})
+	}
+
+	t.Run("omits custom data", func(t *testing.T) {
+		cdEvent, err := cdeventsv04.NewChangeMergedEvent()
+		require.NoError(t, err, "unable to create CDEvent for tests")
+		require.NoError(t, addWebhookEventAsCustomData(kindGiteaPullRequest, payload, cdEvent))
+
+		require.NoError(t, CustomDataPolicy{Mode: CustomDataNone}.Apply(cdEvent), "no error should be returned")
+
+		marshalled, err := json.Marshal(cdEvent)
+		require.NoError(t, err, "event should be marshalled")
+		assert.NotContains(t, string(marshalled), "customData", "event should have no custom data")
+	})
+}
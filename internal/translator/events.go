@@ -0,0 +1,120 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv03 "github.com/cdevents/sdk-go/pkg/api/v03"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+)
+
+// newEvent creates an empty event of the unversioned type, e.g.
+// "dev.cdevents.change.merged", in the spec version.
+func newEvent(eventType string, specVersion string) (cdevents.CDEvent, error) {
+	switch specVersion {
+	case cdeventsv04.SpecVersion:
+		if receiver, ok := cdeventsv04.CDEventsByUnversionedTypes[eventType]; ok {
+			return cdeventsv04.NewCDEvent(receiver.GetType().String(), specVersion)
+		}
+	case cdeventsv03.SpecVersion:
+		if receiver, ok := cdeventsv03.CDEventsByUnversionedTypes[eventType]; ok {
+			return cdeventsv03.NewCDEvent(receiver.GetType().String(), specVersion)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spec version %q", specVersion)
+	}
+	return nil, fmt.Errorf("unknown event type %q in spec version %s", eventType, specVersion)
+}
+
+// ParseEvent reads an event of any type and spec version known to the SDK, or
+// a custom event of spec version 0.4.1, from its JSON and validates it. Unlike
+// the NewFromJsonBytes functions of the SDK, which read every event of a type
+// into the same instance, it returns a new event each time.
+func ParseEvent(data []byte) (cdevents.CDEvent, error) {
+
+	var document struct {
+		Context struct {
+			Version string               `json:"version"`
+			Type    cdevents.CDEventType `json:"type"`
+		} `json:"context"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	eventType := document.Context.Type
+
+	var cdEvent cdevents.CDEvent
+	var receiver interface{}
+	if eventType.Custom != "" {
+		if document.Context.Version != cdeventsv04.SpecVersion {
+			return nil, fmt.Errorf("custom event type %s requires spec version %s", eventType, cdeventsv04.SpecVersion)
+		}
+		customTypeEvent, err := cdeventsv04.NewCustomTypeEvent()
+		if err != nil {
+			return nil, err
+		}
+		cdEvent, receiver = customEvent{customTypeEvent}, customTypeEvent
+	} else {
+		var err error
+		if cdEvent, err = newEvent(eventType.UnversionedString(), document.Context.Version); err != nil {
+			return nil, err
+		}
+		if !eventType.IsCompatible(cdEvent.GetType()) {
+			return nil, fmt.Errorf("event type %s is not compatible with %s", eventType, cdEvent.GetType())
+		}
+		receiver = cdEvent
+	}
+
+	if err := json.Unmarshal(data, receiver); err != nil {
+		return nil, err
+	}
+
+	if err := cdevents.Validate(cdEvent); err != nil {
+		return nil, fmt.Errorf("invalid event: %w", err)
+	}
+
+	return cdEvent, nil
+}
+
+// ConvertEvent converts the event to the spec version by carrying its context,
+// subject and custom data over to a new event of the same type in that version,
+// which is validated. Fields that the spec version does not have are dropped.
+// Custom events, which only exist in spec version 0.4.1, are returned as they
+// are, as are all events when the spec version is empty.
+func ConvertEvent(cdEvent cdevents.CDEvent, specVersion string) (cdevents.CDEvent, error) {
+	if specVersion == "" || cdEvent.GetVersion() == specVersion || cdEvent.GetType().Custom != "" {
+		return cdEvent, nil
+	}
+
+	converted, err := newEvent(cdEvent.GetType().UnversionedString(), specVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(cdEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	context, ok := document["context"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("event of type %s has no context", cdEvent.GetType())
+	}
+	context["version"] = specVersion
+	context["type"] = converted.GetType()
+
+	if data, err = json.Marshal(document); err != nil {
+		return nil, err
+	}
+
+	return ParseEvent(data)
+}
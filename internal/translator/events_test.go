@@ -0,0 +1,130 @@
+package translator
+
+import (
+	"testing"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv03 "github.com/cdevents/sdk-go/pkg/api/v03"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvent(t *testing.T) {
+
+	changeMergedV04, err := cdeventsv04.NewChangeMergedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedV04.SetSource("git.example.com")
+	changeMergedV04.SetSubjectId("42")
+
+	changeMergedV03, err := cdeventsv03.NewChangeMergedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedV03.SetSource("git.example.com")
+	changeMergedV03.SetSubjectId("42")
+
+	tagEvent, err := newTagEvent(TagCreatedEventType, "yoloco/project1", "v1.0.0", "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2")
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	tagEvent.SetSource("git.example.com")
+
+	for _, tc := range []struct {
+		title        string
+		event        cdevents.CDEvent
+		expectedType cdevents.CDEventType
+	}{
+		{"parses event of spec version 0.4.1", changeMergedV04, cdevents.ChangeMergedEventTypeV0_2_0},
+		{"parses event of spec version 0.3.0", changeMergedV03, changeMergedV03.GetType()},
+		{"parses custom event", tagEvent, tagEvent.GetType()},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			data, err := cdevents.AsJsonBytes(tc.event)
+			require.NoError(t, err, "event should be rendered")
+
+			cdEvent, err := ParseEvent(data)
+			require.NoError(t, err, "no error should be returned when parsing event")
+			assert.Equal(t, tc.expectedType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, tc.event.GetVersion(), cdEvent.GetVersion(), "Event must have spec version of JSON")
+			assert.Equal(t, tc.event.GetSubjectId(), cdEvent.GetSubjectId(), "Subject ID must be that of JSON")
+		})
+	}
+
+	for _, tc := range []struct {
+		title string
+		data  string
+	}{
+		{"error on malformed JSON", `{"context": `},
+		{"error on unsupported spec version", `{"context": {"version": "0.2.0", "type": "dev.cdevents.change.merged.0.1.2"}}`},
+		{"error on incompatible event type", `{"context": {"version": "0.4.1", "type": "dev.cdevents.change.merged.0.1.2"}}`},
+		{"error on invalid event", `{"context": {"version": "0.4.1", "type": "dev.cdevents.change.merged.0.2.0"}}`},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := ParseEvent([]byte(tc.data))
+			assert.Error(t, err, "error should be returned")
+		})
+	}
+}
+
+func TestConvertEvent(t *testing.T) {
+
+	changeMergedV04, err := cdeventsv04.NewChangeMergedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedV04.SetSource("git.example.com")
+	changeMergedV04.SetSubjectId("42")
+	changeMergedV04.SetSubjectRepository(&cdevents.Reference{Id: "yoloco/project1"})
+	changeMergedV04.SetChainId("4c8cb7dd-3448-41de-8768-eec704e2829b")
+	require.NoError(t, changeMergedV04.SetCustomData("application/json", map[string]interface{}{"Kind": "gitea.pull_request/v1", "Number": 42}))
+
+	t.Run("converts event to spec version 0.3.0", func(t *testing.T) {
+		cdEvent, err := ConvertEvent(changeMergedV04, cdeventsv03.SpecVersion)
+		require.NoError(t, err, "no error should be returned when converting event")
+
+		assert.Equal(t, cdeventsv03.SpecVersion, cdEvent.GetVersion(), "Event must have converted spec version")
+		assert.Equal(t, cdevents.ChangeMergedEventTypeV0_1_2, cdEvent.GetType(), "Event must have type of converted spec version")
+		assert.Equal(t, changeMergedV04.GetId(), cdEvent.GetId(), "Event ID must be kept")
+		assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be kept")
+		assert.Equal(t, "42", cdEvent.GetSubjectId(), "Subject ID must be kept")
+		assert.Equal(t, changeMergedV04.GetTimestamp().UTC(), cdEvent.GetTimestamp().UTC(), "Timestamp must be kept")
+
+		content, ok := cdEvent.GetSubjectContent().(cdevents.ChangeMergedSubjectContentV0_1_2)
+		require.True(t, ok, "failed to cast Subject Content")
+		require.NotNil(t, content.Repository, "Content repository must not be nil")
+		assert.Equal(t, "yoloco/project1", content.Repository.Id, "Content repository must be kept")
+
+		customData, err := cdEvent.GetCustomDataRaw()
+		require.NoError(t, err, "custom data should be marshalled")
+		assert.JSONEq(t, `{"Kind": "gitea.pull_request/v1", "Number": 42}`, string(customData), "custom data must be kept")
+
+		t.Run("and back to spec version 0.4.1", func(t *testing.T) {
+			converted, err := ConvertEvent(cdEvent, cdeventsv04.SpecVersion)
+			require.NoError(t, err, "no error should be returned when converting event")
+			assert.Equal(t, cdeventsv04.SpecVersion, converted.GetVersion(), "Event must have converted spec version")
+			assert.Equal(t, cdevents.ChangeMergedEventTypeV0_2_0, converted.GetType(), "Event must have type of converted spec version")
+			assert.Equal(t, "42", converted.GetSubjectId(), "Subject ID must be kept")
+		})
+	})
+
+	t.Run("keeps event of same spec version", func(t *testing.T) {
+		cdEvent, err := ConvertEvent(changeMergedV04, cdeventsv04.SpecVersion)
+		require.NoError(t, err, "no error should be returned")
+		assert.Same(t, changeMergedV04, cdEvent, "event must be kept")
+	})
+
+	t.Run("keeps event without spec version", func(t *testing.T) {
+		cdEvent, err := ConvertEvent(changeMergedV04, "")
+		require.NoError(t, err, "no error should be returned")
+		assert.Same(t, changeMergedV04, cdEvent, "event must be kept")
+	})
+
+	t.Run("keeps custom event", func(t *testing.T) {
+		tagEvent, err := newTagEvent(TagCreatedEventType, "yoloco/project1", "v1.0.0", "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2")
+		require.NoError(t, err, "unable to create CDEvent for tests")
+
+		cdEvent, err := ConvertEvent(tagEvent, cdeventsv03.SpecVersion)
+		require.NoError(t, err, "no error should be returned")
+		assert.Equal(t, cdeventsv04.SpecVersion, cdEvent.GetVersion(), "custom event must keep its spec version")
+	})
+
+	t.Run("error on unsupported spec version", func(t *testing.T) {
+		_, err := ConvertEvent(changeMergedV04, "0.2.0")
+		assert.Error(t, err, "error should be returned")
+	})
+}
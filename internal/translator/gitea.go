@@ -5,64 +5,239 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/structs"
 	cdevents "github.com/cdevents/sdk-go/pkg/api"
 	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/package-url/packageurl-go"
 )
 
-var (
-	ErrNoCommitsOnPushEvent  error = errors.New("Push event contains no new commits, will not convert to a CD Event")
-	ErrMissingRequiredFields error = errors.New("Event payload is missing required fields, cannot convert to a CD Event")
-	ErrUnsupportedPRAction   error = errors.New("Unsupported Gitea Pull Request action, cannot convert to a CD Event")
-	ErrUnsupportedRefType    error = errors.New("Unsupported Gitea Create ref type, cannot convert to a CD Event")
+// Sources of the events of created and deleted branches and tags in Gitea,
+// which sends both a push webhook and a create or delete webhook for them.
+const (
+	RefEventsFromPush   = "push"
+	RefEventsFromCreate = "create"
 )
 
-type GiteaPush struct{}
+// ParseRefEvents checks that the source of the events of created and deleted
+// branches and tags is one of the webhooks that Gitea sends for them.
+func ParseRefEvents(source string) (string, error) {
+	switch source {
+	case RefEventsFromPush, RefEventsFromCreate:
+		return source, nil
+	}
+	return "", fmt.Errorf("unknown source of ref events %q, expected %q or %q", source, RefEventsFromPush, RefEventsFromCreate)
+}
+
+// GiteaPush translates pushes to the merge branches given by the branch policy
+// into ChangeMerged events, and pushes to other branches into ChangeUpdated
+// events unless the policy says that they should be ignored. Pushes creating or
+// deleting a branch are translated into BranchCreated or BranchDeleted events,
+// followed by the change event for any commits pushed with a new branch, and
+// pushes of tags into tag events, unless RefEvents says that they are
+// translated from create and delete webhooks.
+// When Merges is set, pushes to merge branches wait up to MergeWait for a pull
+// request to report the merge and are ignored if one does.
+type GiteaPush struct {
+	BranchPolicy BranchPolicy
+	Merges       MergeRecorder
+	MergeWait    time.Duration
+	RefEvents    string
+}
 
-func (g *GiteaPush) Translate(data []byte) (cdevents.CDEvent, error) {
+func (g *GiteaPush) Translate(req Request) ([]cdevents.CDEvent, error) {
 
 	var giteaEvent structs.GiteaPushEvent
-	if err := json.Unmarshal(data, &giteaEvent); err != nil {
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
 		return nil, err
 	}
 
-	cdEvent, err := cdeventsv04.NewChangeMergedEvent()
+	if tag, isTag := strings.CutPrefix(giteaEvent.Ref, "refs/tags/"); isTag {
+		cdEvent, err := g.translateTagPush(giteaEvent, tag)
+		if err != nil {
+			return nil, err
+		}
+		return []cdevents.CDEvent{cdEvent}, nil
+	}
+
+	if giteaEvent.After == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if giteaEvent.Repository.FullName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvents []cdevents.CDEvent
+
+	branch, isBranch := strings.CutPrefix(giteaEvent.Ref, "refs/heads/")
+	if isBranch && (isZeroSha(giteaEvent.Before) || isZeroSha(giteaEvent.After)) && g.RefEvents != RefEventsFromCreate {
+		branchEvent, err := g.translateBranchPush(giteaEvent, branch)
+		if err != nil {
+			return nil, err
+		}
+		cdEvents = append(cdEvents, branchEvent)
+	}
+
+	if giteaEvent.TotalCommits == 0 {
+		if len(cdEvents) == 0 {
+			return nil, Ignore(ReasonNoCommits, "push to %s has no new commits", giteaEvent.Ref)
+		}
+		return cdEvents, nil
+	}
+
+	changeEvent, err := g.translateCommitPush(giteaEvent, branch, isBranch, req)
+	if errors.Is(err, ErrIgnored) && len(cdEvents) > 0 {
+		return cdEvents, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	return append(cdEvents, changeEvent), nil
+}
+
+// translateCommitPush translates the commits pushed into a ChangeMerged or
+// ChangeUpdated event depending on the branch policy.
+func (g *GiteaPush) translateCommitPush(giteaEvent structs.GiteaPushEvent, branch string, isBranch bool, req Request) (cdevents.CDEvent, error) {
+
+	repository := &cdevents.Reference{Id: giteaEvent.Repository.FullName}
+
+	var cdEvent cdevents.CDEvent
+
+	switch {
+	case isBranch && g.BranchPolicy.IsMergeBranch(giteaEvent.Repository.FullName, giteaEvent.Repository.DefaultBranch, branch):
+		changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeMergedEvent.SetSubjectRepository(repository)
+		cdEvent = changeMergedEvent
+	case g.BranchPolicy.IgnoreOtherBranches:
+		return nil, Ignore(ReasonPolicy, "push to %s is not to a merge branch", giteaEvent.Ref)
+	default:
+		changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeUpdatedEvent.SetSubjectRepository(repository)
+		cdEvent = changeUpdatedEvent
+	}
+
 	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
 		return nil, ErrMissingRequiredFields
 	}
 
-	if giteaEvent.TotalCommits == 0 {
-		return nil, ErrNoCommitsOnPushEvent
+	cdEvent.SetSubjectId(giteaEvent.After)
+	setTimestampFromPayload(cdEvent, giteaEvent.HeadCommit.Timestamp)
+
+	if err := addWebhookEventAsCustomData(kindGiteaPush, giteaEvent, cdEvent); err != nil {
+		return nil, err
 	}
 
-	if giteaEvent.After == "" {
+	if cdEvent.GetType().Predicate == "merged" {
+		if err := checkMerge(g.Merges, giteaEvent.Repository.FullName, giteaEvent.After, req, g.MergeWait); err != nil {
+			return nil, err
+		}
+	}
+
+	return cdEvent, nil
+}
+
+// translateBranchPush translates a push creating or deleting a branch into a
+// BranchCreated or BranchDeleted event.
+func (g *GiteaPush) translateBranchPush(giteaEvent structs.GiteaPushEvent, branch string) (cdevents.CDEvent, error) {
+
+	if branch == "" {
 		return nil, ErrMissingRequiredFields
 	}
-	cdEvent.SetSubjectId(giteaEvent.After)
 
-	if giteaEvent.Repository.FullName == "" {
+	repository := &cdevents.Reference{Id: giteaEvent.Repository.FullName}
+
+	var cdEvent cdevents.CDEvent
+
+	if isZeroSha(giteaEvent.After) {
+		branchDeletedEvent, err := cdeventsv04.NewBranchDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchDeletedEvent.SetSubjectRepository(repository)
+		cdEvent = branchDeletedEvent
+	} else {
+		branchCreatedEvent, err := cdeventsv04.NewBranchCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchCreatedEvent.SetSubjectRepository(repository)
+		cdEvent = branchCreatedEvent
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
 		return nil, ErrMissingRequiredFields
 	}
-	cdEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
 
-	if err := addGiteaEventAsCustomData(giteaEvent, cdEvent); err != nil {
+	cdEvent.SetSubjectId(branch)
+
+	if err := addWebhookEventAsCustomData(kindGiteaPush, giteaEvent, cdEvent); err != nil {
 		return nil, err
 	}
 
 	return cdEvent, nil
 }
 
-type GiteaPullRequest struct{}
+// translateTagPush translates a push of a tag into a tag created event with the
+// pushed sha, or a tag deleted event if the tag was removed.
+func (g *GiteaPush) translateTagPush(giteaEvent structs.GiteaPushEvent, tag string) (cdevents.CDEvent, error) {
+
+	if tag == "" || giteaEvent.After == "" || giteaEvent.Repository.FullName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if g.RefEvents == RefEventsFromCreate {
+		return nil, Ignore(ReasonDuplicate, "tag %s is reported by create and delete webhooks", tag)
+	}
+
+	var cdEvent cdevents.CDEvent
+	var err error
+	if isZeroSha(giteaEvent.After) {
+		cdEvent, err = newTagEvent(TagDeletedEventType, giteaEvent.Repository.FullName, tag, "")
+	} else {
+		cdEvent, err = newTagEvent(TagCreatedEventType, giteaEvent.Repository.FullName, tag, giteaEvent.After)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	setTimestampFromPayload(cdEvent, giteaEvent.HeadCommit.Timestamp)
 
-func (g *GiteaPullRequest) Translate(data []byte) (cdevents.CDEvent, error) {
+	if err := addWebhookEventAsCustomData(kindGiteaPush, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return cdEvent, nil
+}
+
+// giteaPullRequestIgnoredActions are the pull request actions that there are
+// no events for.
+var giteaPullRequestIgnoredActions = []string{"assigned", "unassigned", "label_updated", "label_cleared", "milestoned", "demilestoned", "reviewed", "review_requested", "review_request_removed"}
+
+// GiteaPullRequest translates pull request webhooks. When Merges is set, the
+// merge commits of merged pull requests are recorded so that the pushes of the
+// merges are not reported again.
+type GiteaPullRequest struct {
+	Merges MergeRecorder
+}
+
+func (g *GiteaPullRequest) Translate(req Request) ([]cdevents.CDEvent, error) {
 
 	var giteaEvent structs.GiteaPullRequestEvent
-	if err := json.Unmarshal(data, &giteaEvent); err != nil {
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
 		return nil, err
 	}
 
@@ -89,14 +264,33 @@ func (g *GiteaPullRequest) Translate(data []byte) (cdevents.CDEvent, error) {
 		changeCreatedEvent.SetSubjectDescription(giteaEvent.PullRequest.Title)
 		cdEvent = changeCreatedEvent
 	case "closed":
-		changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+		if giteaEvent.PullRequest.Merged {
+			changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+			if err != nil {
+				return nil, err
+			}
+			changeMergedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+			cdEvent = changeMergedEvent
+		} else {
+			changeAbandonedEvent, err := cdeventsv04.NewChangeAbandonedEvent()
+			if err != nil {
+				return nil, err
+			}
+			changeAbandonedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+			cdEvent = changeAbandonedEvent
+		}
+	case "reopened", "synchronized", "edited":
+		// A reopened PR continues the lifecycle of the change that was created
+		// when it was first opened, so it is reported as an update rather than
+		// a new change.
+		changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
 		if err != nil {
 			return nil, err
 		}
-		changeMergedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
-		cdEvent = changeMergedEvent
+		changeUpdatedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+		cdEvent = changeUpdatedEvent
 	default:
-		return nil, ErrUnsupportedPRAction
+		return nil, ignoreKnown(ReasonUnsupportedAction, giteaEvent.Action, giteaPullRequestIgnoredActions, ErrUnsupportedPRAction)
 	}
 
 	addSourcesFromRepositoryUrl(giteaEvent, cdEvent)
@@ -105,60 +299,791 @@ func (g *GiteaPullRequest) Translate(data []byte) (cdevents.CDEvent, error) {
 		return nil, ErrMissingRequiredFields
 	}
 	cdEvent.SetSubjectId(fmt.Sprintf("pr-%d", giteaEvent.PullRequest.Id))
-	if err := cdEvent.SetCustomData("application/json", giteaEvent); err != nil {
+	setTimestampFromPayload(cdEvent, pullRequestTimestamps(giteaEvent.Action, giteaEvent.PullRequest.CreatedAt, giteaEvent.PullRequest.UpdatedAt, giteaEvent.PullRequest.ClosedAt, giteaEvent.PullRequest.MergedAt)...)
+
+	if err := addWebhookEventAsCustomData(kindGiteaPullRequest, giteaEvent, cdEvent); err != nil {
 		return nil, err
 	}
 
-	if err := addGiteaEventAsCustomData(giteaEvent, cdEvent); err != nil {
+	if giteaEvent.Action == "closed" && giteaEvent.PullRequest.Merged {
+		if err := recordMerge(g.Merges, giteaEvent.Repository.FullName, giteaEvent.PullRequest.MergeCommitSha); err != nil {
+			return nil, err
+		}
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// GiteaPullRequestReview translates the approved, rejected and comment review
+// webhooks, which Gitea distinguishes by the X-Gitea-Event-Type header.
+type GiteaPullRequestReview struct{}
+
+func (g *GiteaPullRequestReview) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaPullRequestEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
 		return nil, err
 	}
 
-	return cdEvent, nil
+	if giteaEvent.Repository.FullName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if giteaEvent.Review == nil || giteaEvent.Review.Type == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	reviewState, found := strings.CutPrefix(giteaEvent.Review.Type, "pull_request_review_")
+	if !found {
+		return nil, ErrUnsupportedPRAction
+	}
+
+	cdEvent, err := cdeventsv04.NewChangeReviewedEvent()
+	if err != nil {
+		return nil, err
+	}
+	cdEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if giteaEvent.PullRequest.Id == 0 {
+		return nil, ErrMissingRequiredFields
+	}
+	cdEvent.SetSubjectId(fmt.Sprintf("pr-%d", giteaEvent.PullRequest.Id))
+	// The review in the payload has no time of its own, but submitting it
+	// updates the pull request.
+	setTimestampFromPayload(cdEvent, giteaEvent.PullRequest.UpdatedAt)
+
+	if giteaEvent.Sender.Login == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	customData := reviewCustomData{
+		Kind:        kindGiteaPullRequestReview,
+		Reviewer:    giteaEvent.Sender.Login,
+		ReviewState: reviewState,
+		Content:     giteaEvent,
+	}
+	if err := setCustomData(customData, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
 }
 
-type GiteaCreate struct{}
+// GiteaRelease translates published releases into ArtifactPublished events and
+// deleted releases into ArtifactDeleted events. Draft releases are translated
+// into ArtifactPackaged events when they target a commit, as the payload has no
+// other sha for the change of the event. Updates of published releases are
+// ignored, since they were reported when published. The artifact is identified
+// by a generic package URL of the repository at the release tag.
+type GiteaRelease struct{}
 
-func (g *GiteaCreate) Translate(data []byte) (cdevents.CDEvent, error) {
+func (g *GiteaRelease) Translate(req Request) ([]cdevents.CDEvent, error) {
 
-	var giteaEvent structs.GiteaCreateEvent
-	if err := json.Unmarshal(data, &giteaEvent); err != nil {
+	var giteaEvent structs.GiteaReleaseEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
 		return nil, err
 	}
 
+	if giteaEvent.Repository.Name == "" || giteaEvent.Repository.Owner.Username == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if giteaEvent.Release.TagName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
 	var cdEvent cdevents.CDEvent
 
-	switch giteaEvent.RefType {
-	case "branch":
-		branchCreatedEvent, err := cdeventsv04.NewBranchCreatedEvent()
+	switch {
+	case (giteaEvent.Action == "published" || giteaEvent.Action == "updated") && giteaEvent.Release.Draft:
+		if !isCommitSha(giteaEvent.Release.TargetCommitish) {
+			return nil, Ignore(ReasonNotFinal, "draft release %s targets %s rather than a commit", giteaEvent.Release.TagName, giteaEvent.Release.TargetCommitish)
+		}
+		artifactPackagedEvent, err := cdeventsv04.NewArtifactPackagedEvent()
 		if err != nil {
 			return nil, err
 		}
-		branchCreatedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
-		cdEvent = branchCreatedEvent
+		artifactPackagedEvent.SetSubjectChange(&cdevents.Reference{Id: giteaEvent.Release.TargetCommitish})
+		cdEvent = artifactPackagedEvent
+	case giteaEvent.Action == "published":
+		artifactPublishedEvent, err := cdeventsv04.NewArtifactPublishedEvent()
+		if err != nil {
+			return nil, err
+		}
+		artifactPublishedEvent.SetSubjectUser(giteaEvent.Release.Author.Login)
+		cdEvent = artifactPublishedEvent
+	case giteaEvent.Action == "updated":
+		return nil, Ignore(ReasonAlreadyReported, "release %s was reported when published", giteaEvent.Release.TagName)
+	case giteaEvent.Action == "deleted":
+		artifactDeletedEvent, err := cdeventsv04.NewArtifactDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		artifactDeletedEvent.SetSubjectUser(giteaEvent.Sender.Login)
+		cdEvent = artifactDeletedEvent
 	default:
-		return nil, ErrUnsupportedRefType
+		return nil, ErrUnsupportedAction
 	}
 
-	addSourcesFromRepositoryUrl(giteaEvent, cdEvent)
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
 
-	if giteaEvent.Ref == "" {
+	purl := packageurl.NewPackageURL(
+		packageurl.TypeGeneric,
+		giteaEvent.Repository.Owner.Username,
+		giteaEvent.Repository.Name,
+		giteaEvent.Release.TagName,
+		packageurl.QualifiersFromMap(map[string]string{"vcs_url": "git+" + giteaEvent.Repository.HtmlUrl}),
+		"",
+	)
+	cdEvent.SetSubjectId(purl.ToString())
+	setTimestampFromPayload(cdEvent, releaseTimestamps(giteaEvent)...)
+
+	customData := releaseCustomData{
+		Kind:    kindGiteaRelease,
+		Assets:  giteaEvent.Release.Assets,
+		Content: giteaEvent,
+	}
+	if err := setCustomData(customData, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// releaseTimestamps returns the timestamps of the release that date the action:
+// when it was published, or created for drafts. The payload has no time for
+// deletions.
+func releaseTimestamps(giteaEvent structs.GiteaReleaseEvent) []string {
+	switch {
+	case giteaEvent.Action == "deleted":
+		return nil
+	case giteaEvent.Release.Draft:
+		return []string{giteaEvent.Release.CreatedAt}
+	default:
+		return []string{giteaEvent.Release.PublishedAt, giteaEvent.Release.CreatedAt}
+	}
+}
+
+// GiteaPackage translates package registry webhooks into ArtifactPublished
+// events for created packages and ArtifactDeleted events for deleted ones, with
+// a package URL of the package type as subject id.
+type GiteaPackage struct{}
+
+func (g *GiteaPackage) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaPackageEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.Package.Type == "" || giteaEvent.Package.Name == "" || giteaEvent.Package.Version == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if giteaEvent.Package.HtmlUrl == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	packageUrl, err := url.Parse(giteaEvent.Package.HtmlUrl)
+	if err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch giteaEvent.Action {
+	case "created":
+		artifactPublishedEvent, err := cdeventsv04.NewArtifactPublishedEvent()
+		if err != nil {
+			return nil, err
+		}
+		artifactPublishedEvent.SetSubjectUser(giteaEvent.Package.Creator.Login)
+		cdEvent = artifactPublishedEvent
+	case "deleted":
+		artifactDeletedEvent, err := cdeventsv04.NewArtifactDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		artifactDeletedEvent.SetSubjectUser(giteaEvent.Sender.Login)
+		cdEvent = artifactDeletedEvent
+	default:
+		return nil, ErrUnsupportedAction
+	}
+
+	if err := addSourcesFromUrl(giteaEvent.Package.HtmlUrl, cdEvent); err != nil {
 		return nil, ErrMissingRequiredFields
 	}
-	cdEvent.SetSubjectId(giteaEvent.Ref)
 
-	if err := addGiteaEventAsCustomData(giteaEvent, cdEvent); err != nil {
+	cdEvent.SetSubjectId(giteaPackageUrl(giteaEvent, packageUrl.Host))
+	if giteaEvent.Action == "created" {
+		setTimestampFromPayload(cdEvent, giteaEvent.Package.CreatedAt)
+	}
+
+	if err := addWebhookEventAsCustomData(kindGiteaPackage, giteaEvent, cdEvent); err != nil {
 		return nil, err
 	}
 
-	return cdEvent, nil
+	return []cdevents.CDEvent{cdEvent}, nil
 }
 
-type GiteaDelete struct{}
+// giteaPackageUrl returns the package URL of the package in the registry at
+// the host. Package types without a package URL type of their own get a
+// generic package URL namespaced by the package owner.
+func giteaPackageUrl(giteaEvent structs.GiteaPackageEvent, host string) string {
 
-func (g *GiteaDelete) Translate(data []byte) (cdevents.CDEvent, error) {
+	pkg := giteaEvent.Package
+	owner := pkg.Owner.Login
 
-	var giteaEvent structs.GiteaDeleteEvent
-	if err := json.Unmarshal(data, &giteaEvent); err != nil {
+	var purl *packageurl.PackageURL
+
+	switch pkg.Type {
+	case "container":
+		// Images are identified by digest, with tags given as a qualifier.
+		qualifiers := map[string]string{"repository_url": fmt.Sprintf("%s/%s/%s", host, owner, pkg.Name)}
+		version := pkg.Version
+		if !strings.HasPrefix(version, "sha256:") {
+			qualifiers["tag"] = version
+			version = ""
+		}
+		name := pkg.Name[strings.LastIndex(pkg.Name, "/")+1:]
+		purl = packageurl.NewPackageURL(packageurl.TypeOCI, "", strings.ToLower(name), version, packageurl.QualifiersFromMap(qualifiers), "")
+	case "npm":
+		namespace, name, found := strings.Cut(pkg.Name, "/")
+		if !found {
+			namespace, name = "", pkg.Name
+		}
+		qualifiers := map[string]string{"repository_url": fmt.Sprintf("%s/api/packages/%s/npm", host, owner)}
+		purl = packageurl.NewPackageURL(packageurl.TypeNPM, namespace, name, pkg.Version, packageurl.QualifiersFromMap(qualifiers), "")
+	case "go":
+		namespace, name := "", pkg.Name
+		if i := strings.LastIndex(pkg.Name, "/"); i >= 0 {
+			namespace, name = pkg.Name[:i], pkg.Name[i+1:]
+		}
+		purl = packageurl.NewPackageURL(packageurl.TypeGolang, namespace, name, pkg.Version, nil, "")
+	case "pypi", "cargo", "nuget":
+		qualifiers := map[string]string{"repository_url": fmt.Sprintf("%s/api/packages/%s/%s", host, owner, pkg.Type)}
+		purl = packageurl.NewPackageURL(pkg.Type, "", pkg.Name, pkg.Version, packageurl.QualifiersFromMap(qualifiers), "")
+	default:
+		qualifiers := map[string]string{"package_type": pkg.Type, "repository_url": fmt.Sprintf("%s/api/packages/%s/%s", host, owner, pkg.Type)}
+		purl = packageurl.NewPackageURL(packageurl.TypeGeneric, owner, pkg.Name, pkg.Version, packageurl.QualifiersFromMap(qualifiers), "")
+	}
+
+	return purl.ToString()
+}
+
+// GiteaWorkflowRun translates Gitea Actions workflow runs into PipelineRun
+// events as they are requested, start and complete.
+type GiteaWorkflowRun struct{}
+
+func (g *GiteaWorkflowRun) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaWorkflowRunEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.WorkflowRun.Id == 0 {
+		return nil, ErrMissingRequiredFields
+	}
+
+	pipelineName := giteaEvent.Workflow.Name
+	if pipelineName == "" {
+		pipelineName = giteaEvent.WorkflowRun.Name
+	}
+	if pipelineName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch giteaEvent.Action {
+	case "requested":
+		pipelineRunQueuedEvent, err := cdeventsv04.NewPipelineRunQueuedEvent()
+		if err != nil {
+			return nil, err
+		}
+		pipelineRunQueuedEvent.SetSubjectPipelineName(pipelineName)
+		pipelineRunQueuedEvent.SetSubjectUrl(giteaEvent.WorkflowRun.HtmlUrl)
+		setTimestampFromPayload(pipelineRunQueuedEvent, giteaEvent.WorkflowRun.CreatedAt)
+		cdEvent = pipelineRunQueuedEvent
+	case "in_progress":
+		pipelineRunStartedEvent, err := cdeventsv04.NewPipelineRunStartedEvent()
+		if err != nil {
+			return nil, err
+		}
+		pipelineRunStartedEvent.SetSubjectPipelineName(pipelineName)
+		pipelineRunStartedEvent.SetSubjectUrl(giteaEvent.WorkflowRun.HtmlUrl)
+		setTimestampFromPayload(pipelineRunStartedEvent, giteaEvent.WorkflowRun.RunStartedAt, giteaEvent.WorkflowRun.UpdatedAt)
+		cdEvent = pipelineRunStartedEvent
+	case "completed":
+		pipelineRunFinishedEvent, err := cdeventsv04.NewPipelineRunFinishedEvent()
+		if err != nil {
+			return nil, err
+		}
+		pipelineRunFinishedEvent.SetSubjectPipelineName(pipelineName)
+		pipelineRunFinishedEvent.SetSubjectUrl(giteaEvent.WorkflowRun.HtmlUrl)
+		outcome, errs := outcomeFromConclusion(giteaEvent.WorkflowRun.Conclusion)
+		pipelineRunFinishedEvent.SetSubjectOutcome(outcome)
+		pipelineRunFinishedEvent.SetSubjectErrors(errs)
+		setTimestampFromPayload(pipelineRunFinishedEvent, giteaEvent.WorkflowRun.CompletedAt, giteaEvent.WorkflowRun.UpdatedAt)
+		cdEvent = pipelineRunFinishedEvent
+	default:
+		return nil, ErrUnsupportedAction
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	cdEvent.SetSubjectId(fmt.Sprintf("%d", giteaEvent.WorkflowRun.Id))
+
+	if err := addWebhookEventAsCustomData(kindGiteaWorkflowRun, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// GiteaWorkflowJob translates the jobs of Gitea Actions workflow runs into
+// TaskRun events as they start and complete. Queued jobs are ignored since
+// there is no CDEvent for task runs being queued.
+type GiteaWorkflowJob struct{}
+
+func (g *GiteaWorkflowJob) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaWorkflowJobEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.WorkflowJob.Id == 0 || giteaEvent.WorkflowJob.Name == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var pipelineRun *cdevents.Reference
+	if giteaEvent.WorkflowJob.RunId != 0 {
+		pipelineRun = &cdevents.Reference{Id: fmt.Sprintf("%d", giteaEvent.WorkflowJob.RunId)}
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch giteaEvent.Action {
+	case "queued", "waiting":
+		return nil, Ignore(ReasonNotFinal, "workflow job %d is %s", giteaEvent.WorkflowJob.Id, giteaEvent.Action)
+	case "in_progress":
+		taskRunStartedEvent, err := cdeventsv04.NewTaskRunStartedEvent()
+		if err != nil {
+			return nil, err
+		}
+		taskRunStartedEvent.SetSubjectTaskName(giteaEvent.WorkflowJob.Name)
+		taskRunStartedEvent.SetSubjectUrl(giteaEvent.WorkflowJob.HtmlUrl)
+		taskRunStartedEvent.SetSubjectPipelineRun(pipelineRun)
+		setTimestampFromPayload(taskRunStartedEvent, giteaEvent.WorkflowJob.StartedAt)
+		cdEvent = taskRunStartedEvent
+	case "completed":
+		taskRunFinishedEvent, err := cdeventsv04.NewTaskRunFinishedEvent()
+		if err != nil {
+			return nil, err
+		}
+		taskRunFinishedEvent.SetSubjectTaskName(giteaEvent.WorkflowJob.Name)
+		taskRunFinishedEvent.SetSubjectUrl(giteaEvent.WorkflowJob.HtmlUrl)
+		taskRunFinishedEvent.SetSubjectPipelineRun(pipelineRun)
+		outcome, errs := outcomeFromConclusion(giteaEvent.WorkflowJob.Conclusion)
+		taskRunFinishedEvent.SetSubjectOutcome(outcome)
+		taskRunFinishedEvent.SetSubjectErrors(errs)
+		setTimestampFromPayload(taskRunFinishedEvent, giteaEvent.WorkflowJob.CompletedAt)
+		cdEvent = taskRunFinishedEvent
+	default:
+		return nil, ErrUnsupportedAction
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	cdEvent.SetSubjectId(fmt.Sprintf("%d", giteaEvent.WorkflowJob.Id))
+
+	if err := addWebhookEventAsCustomData(kindGiteaWorkflowJob, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// outcomeFromConclusion maps the conclusion of a workflow run or job onto the
+// success, failure and error outcomes of CDEvents, describing conclusions
+// other than success and failure in the returned errors.
+func outcomeFromConclusion(conclusion string) (string, string) {
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return "success", ""
+	case "failure":
+		return "failure", ""
+	case "":
+		return "error", "Workflow completed without conclusion"
+	default:
+		return "error", fmt.Sprintf("Workflow concluded as %s", conclusion)
+	}
+}
+
+// GiteaIssues translates issue webhooks into TicketCreated events for opened
+// issues, TicketClosed events for closed issues and TicketUpdated events for
+// all other changes, such as edits, labels, assignees and milestones.
+type GiteaIssues struct{}
+
+func (g *GiteaIssues) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaIssueEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.Action == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch giteaEvent.Action {
+	case "opened":
+		ticketCreatedEvent, err := cdeventsv04.NewTicketCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		cdEvent = ticketCreatedEvent
+	case "closed":
+		ticketClosedEvent, err := cdeventsv04.NewTicketClosedEvent()
+		if err != nil {
+			return nil, err
+		}
+		ticketClosedEvent.SetSubjectResolution(ticketResolution(giteaEvent))
+		ticketClosedEvent.SetSubjectUpdatedBy(giteaEvent.Sender.Login)
+		cdEvent = ticketClosedEvent
+	case "edited", "reopened", "assigned", "unassigned", "label_updated", "label_cleared", "milestoned", "demilestoned":
+		ticketUpdatedEvent, err := cdeventsv04.NewTicketUpdatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		ticketUpdatedEvent.SetSubjectUpdatedBy(giteaEvent.Sender.Login)
+		cdEvent = ticketUpdatedEvent
+	default:
+		return nil, ErrUnsupportedAction
+	}
+
+	if err := setTicketSubject(giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	switch giteaEvent.Action {
+	case "opened":
+		setTimestampFromPayload(cdEvent, giteaEvent.Issue.CreatedAt)
+	case "closed":
+		setTimestampFromPayload(cdEvent, giteaEvent.Issue.ClosedAt, giteaEvent.Issue.UpdatedAt)
+	default:
+		setTimestampFromPayload(cdEvent, giteaEvent.Issue.UpdatedAt)
+	}
+
+	if err := addWebhookEventAsCustomData(kindGiteaIssues, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// GiteaIssueComment translates comments on issues into TicketUpdated events.
+// Gitea sends comments on pull requests as issue comments too, those are
+// ignored.
+type GiteaIssueComment struct{}
+
+func (g *GiteaIssueComment) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaIssueEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.IsPull || giteaEvent.Issue.PullRequest != nil {
+		return nil, Ignore(ReasonPullRequest, "comment is on a pull request")
+	}
+
+	switch giteaEvent.Action {
+	case "created", "edited", "deleted":
+	default:
+		return nil, ErrUnsupportedAction
+	}
+
+	ticketUpdatedEvent, err := cdeventsv04.NewTicketUpdatedEvent()
+	if err != nil {
+		return nil, err
+	}
+	ticketUpdatedEvent.SetSubjectUpdatedBy(giteaEvent.Sender.Login)
+
+	if err := setTicketSubject(giteaEvent, ticketUpdatedEvent); err != nil {
+		return nil, err
+	}
+
+	// The payload has no time for deleted comments.
+	if giteaEvent.Comment != nil {
+		switch giteaEvent.Action {
+		case "created":
+			setTimestampFromPayload(ticketUpdatedEvent, giteaEvent.Comment.CreatedAt)
+		case "edited":
+			setTimestampFromPayload(ticketUpdatedEvent, giteaEvent.Comment.UpdatedAt)
+		}
+	}
+
+	if err := addWebhookEventAsCustomData(kindGiteaIssueComment, giteaEvent, ticketUpdatedEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{ticketUpdatedEvent}, nil
+}
+
+// ticketSubjectWriter has the setters common to the subjects of all ticket
+// events.
+type ticketSubjectWriter interface {
+	cdevents.CDEvent
+	SetSubjectSummary(summary string)
+	SetSubjectTicketType(ticketType string)
+	SetSubjectGroup(group string)
+	SetSubjectCreator(creator string)
+	SetSubjectAssignees(assignees []string)
+	SetSubjectPriority(priority string)
+	SetSubjectLabels(labels []string)
+	SetSubjectMilestone(milestone string)
+	SetSubjectUri(uri string)
+}
+
+// setTicketSubject sets the subject of the ticket event from the issue, with
+// the repository as group and a scoped "priority/<level>" label, if any, as
+// priority.
+func setTicketSubject(giteaEvent structs.GiteaIssueEvent, cdEvent cdevents.CDEvent) error {
+
+	ticketEvent, ok := cdEvent.(ticketSubjectWriter)
+	if !ok {
+		return fmt.Errorf("not a ticket event: %s", cdEvent.GetType())
+	}
+
+	if giteaEvent.Issue.Id == 0 || giteaEvent.Issue.HtmlUrl == "" {
+		return ErrMissingRequiredFields
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, ticketEvent); err != nil {
+		return ErrMissingRequiredFields
+	}
+
+	ticketEvent.SetSubjectId(fmt.Sprintf("issue-%d", giteaEvent.Issue.Id))
+	ticketEvent.SetSubjectUri(giteaEvent.Issue.HtmlUrl)
+	ticketEvent.SetSubjectSummary(giteaEvent.Issue.Title)
+	ticketEvent.SetSubjectTicketType("issue")
+	ticketEvent.SetSubjectGroup(giteaEvent.Repository.FullName)
+	ticketEvent.SetSubjectCreator(giteaEvent.Issue.User.Login)
+
+	if len(giteaEvent.Issue.Assignees) > 0 {
+		assignees := make([]string, 0, len(giteaEvent.Issue.Assignees))
+		for _, assignee := range giteaEvent.Issue.Assignees {
+			assignees = append(assignees, assignee.Login)
+		}
+		ticketEvent.SetSubjectAssignees(assignees)
+	}
+
+	if len(giteaEvent.Issue.Labels) > 0 {
+		labels := make([]string, 0, len(giteaEvent.Issue.Labels))
+		for _, label := range giteaEvent.Issue.Labels {
+			labels = append(labels, label.Name)
+			if priority, found := strings.CutPrefix(label.Name, "priority/"); found {
+				ticketEvent.SetSubjectPriority(priority)
+			}
+		}
+		ticketEvent.SetSubjectLabels(labels)
+	}
+
+	if giteaEvent.Issue.Milestone != nil {
+		ticketEvent.SetSubjectMilestone(giteaEvent.Issue.Milestone.Title)
+	}
+
+	return nil
+}
+
+// ticketResolution derives the resolution of a closed issue from its labels,
+// as Gitea has no resolution of its own.
+func ticketResolution(giteaEvent structs.GiteaIssueEvent) string {
+	for _, label := range giteaEvent.Issue.Labels {
+		switch strings.ToLower(label.Name) {
+		case "duplicate":
+			return "duplicate"
+		case "wontfix", "invalid":
+			return "withdrawn"
+		}
+	}
+	return "completed"
+}
+
+// GiteaRepository translates repository webhooks, which Gitea only sends when
+// repositories are created or deleted, into RepositoryCreated and
+// RepositoryDeleted events.
+type GiteaRepository struct{}
+
+func (g *GiteaRepository) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaRepositoryEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.Repository.FullName == "" || giteaEvent.Repository.Name == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvent repositorySubjectWriter
+
+	switch giteaEvent.Action {
+	case "created":
+		repositoryCreatedEvent, err := cdeventsv04.NewRepositoryCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		cdEvent = repositoryCreatedEvent
+	case "deleted":
+		repositoryDeletedEvent, err := cdeventsv04.NewRepositoryDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		cdEvent = repositoryDeletedEvent
+	default:
+		return nil, ErrUnsupportedAction
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	cdEvent.SetSubjectId(giteaEvent.Repository.FullName)
+	cdEvent.SetSubjectName(giteaEvent.Repository.Name)
+	cdEvent.SetSubjectOwner(giteaEvent.Repository.Owner.Username)
+	if giteaEvent.Repository.CloneUrl != "" {
+		cdEvent.SetSubjectUrl(giteaEvent.Repository.CloneUrl)
+	} else {
+		cdEvent.SetSubjectUrl(giteaEvent.Repository.HtmlUrl)
+	}
+	cdEvent.SetSubjectViewUrl(giteaEvent.Repository.HtmlUrl)
+
+	if err := addWebhookEventAsCustomData(kindGiteaRepository, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// repositorySubjectWriter has the setters common to the subjects of all
+// repository events.
+type repositorySubjectWriter interface {
+	cdevents.CDEvent
+	SetSubjectName(name string)
+	SetSubjectOwner(owner string)
+	SetSubjectUrl(url string)
+	SetSubjectViewUrl(viewUrl string)
+}
+
+// GiteaStatus translates final commit statuses, typically posted by external
+// CI systems, into TestSuiteRunFinished or PipelineRunFinished events as
+// decided by the status policy. Pending statuses are ignored.
+type GiteaStatus struct {
+	StatusPolicy StatusPolicy
+}
+
+func (g *GiteaStatus) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaStatusEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
+	}
+
+	if giteaEvent.Sha == "" || giteaEvent.Context == "" || giteaEvent.State == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if giteaEvent.State == "pending" {
+		return nil, Ignore(ReasonNotFinal, "status %s of %s is pending", giteaEvent.Context, giteaEvent.Sha)
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch g.StatusPolicy.Kind(giteaEvent.Context) {
+	case StatusKindIgnore:
+		return nil, Ignore(ReasonPolicy, "status context %s is ignored by policy", giteaEvent.Context)
+	case StatusKindTestSuite:
+		testSuiteRunFinishedEvent, err := cdeventsv04.NewTestSuiteRunFinishedEvent()
+		if err != nil {
+			return nil, err
+		}
+		testSuiteRunFinishedEvent.SetSubjectTestSuite(&cdevents.TestSuiteRunFinishedSubjectContentTestSuiteV0_2_0{
+			Id:   giteaEvent.Context,
+			Name: giteaEvent.Context,
+			Uri:  giteaEvent.TargetUrl,
+		})
+		testSuiteRunFinishedEvent.SetSubjectEnvironment(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+		switch giteaEvent.State {
+		case "success", "warning":
+			testSuiteRunFinishedEvent.SetSubjectOutcome("pass")
+		case "failure":
+			testSuiteRunFinishedEvent.SetSubjectOutcome("fail")
+			testSuiteRunFinishedEvent.SetSubjectReason(giteaEvent.Description)
+		default:
+			testSuiteRunFinishedEvent.SetSubjectOutcome("error")
+			testSuiteRunFinishedEvent.SetSubjectReason(giteaEvent.Description)
+		}
+		cdEvent = testSuiteRunFinishedEvent
+	default:
+		pipelineRunFinishedEvent, err := cdeventsv04.NewPipelineRunFinishedEvent()
+		if err != nil {
+			return nil, err
+		}
+		pipelineRunFinishedEvent.SetSubjectPipelineName(giteaEvent.Context)
+		pipelineRunFinishedEvent.SetSubjectUrl(giteaEvent.TargetUrl)
+		switch giteaEvent.State {
+		case "success", "warning":
+			pipelineRunFinishedEvent.SetSubjectOutcome("success")
+		case "failure":
+			pipelineRunFinishedEvent.SetSubjectOutcome("failure")
+			pipelineRunFinishedEvent.SetSubjectErrors(giteaEvent.Description)
+		default:
+			pipelineRunFinishedEvent.SetSubjectOutcome("error")
+			pipelineRunFinishedEvent.SetSubjectErrors(giteaEvent.Description)
+		}
+		cdEvent = pipelineRunFinishedEvent
+	}
+
+	if err := addSourcesFromRepositoryUrl(giteaEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	cdEvent.SetSubjectId(fmt.Sprintf("%s@%s", giteaEvent.Context, giteaEvent.Sha))
+	setTimestampFromPayload(cdEvent, giteaEvent.UpdatedAt, giteaEvent.CreatedAt)
+
+	if err := addWebhookEventAsCustomData(kindGiteaStatus, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// GiteaCreate translates the webhooks of created branches and tags, which are
+// ignored when RefEvents says that they are translated from pushes.
+type GiteaCreate struct {
+	RefEvents string
+}
+
+func (g *GiteaCreate) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var giteaEvent structs.GiteaCreateEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
 		return nil, err
 	}
 
@@ -166,12 +1091,24 @@ func (g *GiteaDelete) Translate(data []byte) (cdevents.CDEvent, error) {
 
 	switch giteaEvent.RefType {
 	case "branch":
-		branchDeletedEvent, err := cdeventsv04.NewBranchDeletedEvent()
+		if g.RefEvents == RefEventsFromPush {
+			return nil, Ignore(ReasonDuplicate, "branch %s is reported by push", giteaEvent.Ref)
+		}
+		branchCreatedEvent, err := cdeventsv04.NewBranchCreatedEvent()
 		if err != nil {
 			return nil, err
 		}
-		branchDeletedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
-		cdEvent = branchDeletedEvent
+		branchCreatedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+		cdEvent = branchCreatedEvent
+	case "tag":
+		if g.RefEvents == RefEventsFromPush {
+			return nil, Ignore(ReasonDuplicate, "tag %s is reported by push", giteaEvent.Ref)
+		}
+		tagCreatedEvent, err := newTagEvent(TagCreatedEventType, giteaEvent.Repository.FullName, giteaEvent.Ref, giteaEvent.Sha)
+		if err != nil {
+			return nil, err
+		}
+		cdEvent = tagCreatedEvent
 	default:
 		return nil, ErrUnsupportedRefType
 	}
@@ -183,60 +1120,62 @@ func (g *GiteaDelete) Translate(data []byte) (cdevents.CDEvent, error) {
 	}
 	cdEvent.SetSubjectId(giteaEvent.Ref)
 
-	if err := addGiteaEventAsCustomData(giteaEvent, cdEvent); err != nil {
+	if err := addWebhookEventAsCustomData(kindGiteaCreate, giteaEvent, cdEvent); err != nil {
 		return nil, err
 	}
 
-	return cdEvent, nil
+	return []cdevents.CDEvent{cdEvent}, nil
 }
 
-func addGiteaEventAsCustomData(giteaEvent interface{}, cdEvent cdevents.CDEvent) error {
-	customData := struct {
-		Kind    string
-		Content interface{}
-	}{
-		Kind:    fmt.Sprintf("%T", giteaEvent),
-		Content: giteaEvent,
-	}
-	if err := cdEvent.SetCustomData("application/json", customData); err != nil {
-		return err
-	}
-	return nil
+// GiteaDelete translates the webhooks of deleted branches and tags, which are
+// ignored when RefEvents says that they are translated from pushes.
+type GiteaDelete struct {
+	RefEvents string
 }
 
-func addSourcesFromRepositoryUrl(giteaEvent interface{}, cdEvent cdevents.CDEvent) error {
+func (g *GiteaDelete) Translate(req Request) ([]cdevents.CDEvent, error) {
 
-	var rawRepoUrl string
-	switch v := giteaEvent.(type) {
-	case structs.GiteaCreateEvent:
-		rawRepoUrl = v.Repository.HtmlUrl
-	case structs.GiteaDeleteEvent:
-		rawRepoUrl = v.Repository.HtmlUrl
-	case structs.GiteaPushEvent:
-		rawRepoUrl = v.Repository.HtmlUrl
-	case structs.GiteaPullRequestEvent:
-		rawRepoUrl = v.Repository.HtmlUrl
-	default:
-		panic(fmt.Sprintf("failed to extract repository URL from Gitea event with type: %T", giteaEvent))
+	var giteaEvent structs.GiteaDeleteEvent
+	if err := json.Unmarshal(req.Payload, &giteaEvent); err != nil {
+		return nil, err
 	}
 
-	if rawRepoUrl == "" {
-		return errors.New("Missing required field: repository.html_url")
-	}
+	var cdEvent cdevents.CDEvent
 
-	repoUrl, err := url.Parse(rawRepoUrl)
-	if err != nil {
-		return err
+	switch giteaEvent.RefType {
+	case "branch":
+		if g.RefEvents == RefEventsFromPush {
+			return nil, Ignore(ReasonDuplicate, "branch %s is reported by push", giteaEvent.Ref)
+		}
+		branchDeletedEvent, err := cdeventsv04.NewBranchDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchDeletedEvent.SetSubjectRepository(&cdevents.Reference{Id: giteaEvent.Repository.FullName})
+		cdEvent = branchDeletedEvent
+	case "tag":
+		if g.RefEvents == RefEventsFromPush {
+			return nil, Ignore(ReasonDuplicate, "tag %s is reported by push", giteaEvent.Ref)
+		}
+		tagDeletedEvent, err := newTagEvent(TagDeletedEventType, giteaEvent.Repository.FullName, giteaEvent.Ref, "")
+		if err != nil {
+			return nil, err
+		}
+		cdEvent = tagDeletedEvent
+	default:
+		return nil, ErrUnsupportedRefType
 	}
 
-	cdEvent.SetSource(repoUrl.Host)
+	addSourcesFromRepositoryUrl(giteaEvent, cdEvent)
 
-	subjectSource, err := url.JoinPath(repoUrl.Host, repoUrl.Path)
-	if err != nil {
-		return err
+	if giteaEvent.Ref == "" {
+		return nil, ErrMissingRequiredFields
 	}
+	cdEvent.SetSubjectId(giteaEvent.Ref)
 
-	cdEvent.SetSubjectSource(subjectSource)
+	if err := addWebhookEventAsCustomData(kindGiteaDelete, giteaEvent, cdEvent); err != nil {
+		return nil, err
+	}
 
-	return nil
+	return []cdevents.CDEvent{cdEvent}, nil
 }
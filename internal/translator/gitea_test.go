@@ -1,12 +1,19 @@
 package translator
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	"github.com/nats-io/nats.go/jetstream"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,19 +26,33 @@ func TestGiteaPush(t *testing.T) {
 		"total_commits": 1,
 		"repository": {
 			"full_name": "yoloco/project1",
-			"html_url": "http://git.example.com/yoloco/project1"
+			"html_url": "http://git.example.com/yoloco/project1",
+			"default_branch": "main"
 		}
 	}	
 	`
 
-	pushNewBranchPayload := `{
+	pushCommitReleaseBranchPayload := `{
+		"ref": "refs/heads/release/1.0",
+		"before": "a359287123178c5d05654864e80ab6f3bfc3d78a",
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"total_commits": 1,
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1",
+			"default_branch": "main"
+		}
+	}`
+
+	pushCommitFeatureBranchPayload := `{
 		"ref": "refs/heads/foo",
-		"before": "0000000000000000000000000000000000000000",
+		"before": "a359287123178c5d05654864e80ab6f3bfc3d78a",
 		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
-		"total_commits": 0,
+		"total_commits": 1,
 		"repository": {
 			"full_name": "yoloco/project1",
-			"html_url": "http://git.example.com/yoloco/project1"
+			"html_url": "http://git.example.com/yoloco/project1",
+			"default_branch": "main"
 		}
 	}`
 
@@ -70,6 +91,7 @@ func TestGiteaPush(t *testing.T) {
 	for _, tc := range []struct {
 		title             string
 		payload           string
+		branchPolicy      BranchPolicy
 		expectedEventType interface{}
 		expectedError     error
 	}{
@@ -79,9 +101,33 @@ func TestGiteaPush(t *testing.T) {
 			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
 		},
 		{
-			title:         "error on push to new branch with no new commits",
-			payload:       pushNewBranchPayload,
-			expectedError: ErrNoCommitsOnPushEvent,
+			title:             "returns ChangeUpdatedEvent on push to other branch payload",
+			payload:           pushCommitFeatureBranchPayload,
+			expectedEventType: cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:             "returns ChangeMergedEvent on push to branch matching pattern for all repositories",
+			payload:           pushCommitReleaseBranchPayload,
+			branchPolicy:      BranchPolicy{Patterns: map[string][]string{"*": {"release/*"}}},
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:             "returns ChangeMergedEvent on push to branch matching pattern for repository",
+			payload:           pushCommitReleaseBranchPayload,
+			branchPolicy:      BranchPolicy{Patterns: map[string][]string{"yoloco/project1": {"release/*"}}},
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:             "returns ChangeUpdatedEvent on push to branch matching pattern for other repository",
+			payload:           pushCommitReleaseBranchPayload,
+			branchPolicy:      BranchPolicy{Patterns: map[string][]string{"yoloco/project2": {"release/*"}}},
+			expectedEventType: cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:         "ignored on push to other branch when policy ignores other branches",
+			payload:       pushCommitFeatureBranchPayload,
+			branchPolicy:  BranchPolicy{IgnoreOtherBranches: true},
+			expectedError: ErrIgnored,
 		},
 		{
 			title:         "error when payload missing repository HTML url field",
@@ -105,12 +151,13 @@ func TestGiteaPush(t *testing.T) {
 		},
 	} {
 		t.Run(tc.title, func(t *testing.T) {
-			translator := &GiteaPush{}
+			translator := &GiteaPush{BranchPolicy: tc.branchPolicy}
 
-			cdEvent, err := translator.Translate([]byte(tc.payload))
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
 
 			if tc.expectedError != nil {
-				assert.Equal(t, tc.expectedError, err)
+				assert.ErrorIs(t, err, tc.expectedError)
 			} else {
 				require.NoError(t, err, "no error should be returned when translating event")
 			}
@@ -128,6 +175,9 @@ func TestGiteaPush(t *testing.T) {
 				case cdevents.ChangeMergedSubjectContentV0_2_0:
 					require.NotNil(t, s.Repository, "Content repository must not be nil")
 					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				case cdevents.ChangeUpdatedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
 				default:
 					require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", s))
 				}
@@ -136,6 +186,148 @@ func TestGiteaPush(t *testing.T) {
 	}
 }
 
+func TestGiteaPushBranch(t *testing.T) {
+
+	zeroSha := "0000000000000000000000000000000000000000"
+
+	branchPushPayload := func(before string, after string, totalCommits int) string {
+		return `{
+			"ref": "refs/heads/foo",
+			"before": "` + before + `",
+			"after": "` + after + `",
+			"total_commits": ` + fmt.Sprint(totalCommits) + `,
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1",
+				"default_branch": "main"
+			}
+		}`
+	}
+
+	for _, tc := range []struct {
+		title              string
+		payload            string
+		refEvents          string
+		expectedEventTypes []string
+		expectedSubjectIds []string
+		expectedReason     string
+	}{
+		{
+			title:              "returns BranchCreatedEvent on push of new branch with no new commits",
+			payload:            branchPushPayload(zeroSha, "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", 0),
+			expectedEventTypes: []string{cdevents.BranchCreatedEventTypeV0_2_0.String()},
+			expectedSubjectIds: []string{"foo"},
+		},
+		{
+			title:              "returns BranchCreatedEvent and ChangeUpdatedEvent on push of new branch with new commits",
+			payload:            branchPushPayload(zeroSha, "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", 2),
+			expectedEventTypes: []string{cdevents.BranchCreatedEventTypeV0_2_0.String(), cdevents.ChangeUpdatedEventTypeV0_2_0.String()},
+			expectedSubjectIds: []string{"foo", "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"},
+		},
+		{
+			title:              "returns BranchDeletedEvent on push deleting branch",
+			payload:            branchPushPayload("9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", zeroSha, 0),
+			expectedEventTypes: []string{cdevents.BranchDeletedEventTypeV0_2_0.String()},
+			expectedSubjectIds: []string{"foo"},
+		},
+		{
+			title:          "ignored on push to existing branch with no new commits",
+			payload:        branchPushPayload("a359287123178c5d05654864e80ab6f3bfc3d78a", "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", 0),
+			expectedReason: ReasonNoCommits,
+		},
+		{
+			title:              "returns only ChangeUpdatedEvent on push of new branch when branches are reported by create webhooks",
+			payload:            branchPushPayload(zeroSha, "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", 2),
+			refEvents:          RefEventsFromCreate,
+			expectedEventTypes: []string{cdevents.ChangeUpdatedEventTypeV0_2_0.String()},
+			expectedSubjectIds: []string{"9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"},
+		},
+		{
+			title:          "ignored on push deleting branch when branches are reported by delete webhooks",
+			payload:        branchPushPayload("9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", zeroSha, 0),
+			refEvents:      RefEventsFromCreate,
+			expectedReason: ReasonNoCommits,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			translator := &GiteaPush{RefEvents: tc.refEvents}
+
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			if tc.expectedReason != "" {
+				assert.ErrorIs(t, err, ErrIgnored)
+				assert.Equal(t, tc.expectedReason, IgnoredReason(err), "reason for ignoring push")
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.Len(t, cdEvents, len(tc.expectedEventTypes), "unexpected number of events")
+
+			for i, cdEvent := range cdEvents {
+				assert.Equal(t, tc.expectedEventTypes[i], cdEvent.GetType().String(), "Event did not have expected type")
+				assert.Equal(t, tc.expectedSubjectIds[i], cdEvent.GetSubjectId(), "Event did not have expected subject id")
+				assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+				assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+			}
+		})
+	}
+}
+
+func TestGiteaRefEvents(t *testing.T) {
+
+	for _, tc := range []struct {
+		title        string
+		examples     map[string]Webhook
+		expectedType cdevents.CDEventType
+	}{
+		{
+			title: "new branch",
+			examples: map[string]Webhook{
+				"push_new_branch.json": &GiteaPush{},
+				"branch_created.json":  &GiteaCreate{},
+			},
+			expectedType: cdevents.BranchCreatedEventTypeV0_2_0,
+		},
+		{
+			title: "new tag",
+			examples: map[string]Webhook{
+				"push_tag.json":    &GiteaPush{},
+				"tag_created.json": &GiteaCreate{},
+			},
+			expectedType: TagCreatedEventType,
+		},
+	} {
+		for _, refEvents := range []string{RefEventsFromPush, RefEventsFromCreate} {
+			t.Run(fmt.Sprintf("%s reported once by %s webhooks", tc.title, refEvents), func(t *testing.T) {
+				var reported int
+				for example, translator := range tc.examples {
+					switch translator := translator.(type) {
+					case *GiteaPush:
+						translator.RefEvents = refEvents
+					case *GiteaCreate:
+						translator.RefEvents = refEvents
+					case *GiteaDelete:
+						translator.RefEvents = refEvents
+					}
+
+					payload, err := os.ReadFile(filepath.Join("..", "..", "examples", "webhooks", "gitea", example))
+					require.NoError(t, err, "example payload should be read")
+
+					cdEvents, err := translator.Translate(Request{Payload: payload})
+					if err != nil {
+						require.ErrorIs(t, err, ErrIgnored, "%s should only be ignored", example)
+					}
+					for _, cdEvent := range cdEvents {
+						if cdEvent.GetType() == tc.expectedType {
+							reported++
+						}
+					}
+				}
+				assert.Equal(t, 1, reported, "%s should be reported by exactly one webhook", tc.expectedType)
+			})
+		}
+	}
+}
+
 func TestGiteaPullRequest(t *testing.T) {
 
 	prOpenedPayload := `{
@@ -150,11 +342,26 @@ func TestGiteaPullRequest(t *testing.T) {
 		}
 	}`
 
+	prMergedPayload := `{
+		"action": "closed",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR",
+			"merged": true
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}
+	`
+
 	prClosedPayload := `{
 		"action": "closed",
 		"pull_request": {
 			"id": 3,
-			"title": "Fix something PR"
+			"title": "Fix something PR",
+			"merged": false
 		},
 		"repository": {
 			"full_name": "yoloco/project1",
@@ -163,6 +370,20 @@ func TestGiteaPullRequest(t *testing.T) {
 	}
 	`
 
+	prActionPayload := func(action string) string {
+		return `{
+			"action": "` + action + `",
+			"pull_request": {
+				"id": 3,
+				"title": "Fix something PR"
+			},
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			}
+		}`
+	}
+
 	prWithUnsupportedAction := `{
 		"action": "unknown",
 		"pull_request": {
@@ -224,10 +445,35 @@ func TestGiteaPullRequest(t *testing.T) {
 			expectedCDEventType: &cdevents.ChangeCreatedEventTypeV0_3_0,
 		},
 		{
-			title:               "Return change merged event on PR closed payload",
-			payload:             prClosedPayload,
+			title:               "Return change merged event on merged PR closed payload",
+			payload:             prMergedPayload,
 			expectedCDEventType: &cdevents.ChangeMergedEventTypeV0_2_0,
 		},
+		{
+			title:               "Return change abandoned event on unmerged PR closed payload",
+			payload:             prClosedPayload,
+			expectedCDEventType: &cdevents.ChangeAbandonedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change updated event on PR reopened payload",
+			payload:             prActionPayload("reopened"),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change updated event on PR synchronized payload",
+			payload:             prActionPayload("synchronized"),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change updated event on PR edited payload",
+			payload:             prActionPayload("edited"),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:         "Ignored on PR label updated payload",
+			payload:       prActionPayload("label_updated"),
+			expectedError: ErrIgnored,
+		},
 		{
 			title:         "Error on unsupported action",
 			payload:       prWithUnsupportedAction,
@@ -250,17 +496,18 @@ func TestGiteaPullRequest(t *testing.T) {
 		},
 	} {
 		t.Run(tc.title, func(t *testing.T) {
-			cdEvent, err := translator.Translate([]byte(tc.payload))
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
 
 			if tc.expectedError != nil {
-				assert.Equal(t, tc.expectedError, err)
+				assert.ErrorIs(t, err, tc.expectedError)
 			} else {
 				require.NoError(t, err, "no error should be returned when translating event")
 			}
 
 			if tc.expectedCDEventType != nil {
 				require.NotNil(t, cdEvent, "CD event must not be nil")
-				assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must be of type ChangeCreatedEvent")
+				assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
 				assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
 				assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
 				assert.Equal(t, "pr-3", cdEvent.GetSubjectId(), "Subject Id must be pr-<number>")
@@ -274,6 +521,12 @@ func TestGiteaPullRequest(t *testing.T) {
 				case cdevents.ChangeMergedSubjectContentV0_2_0:
 					require.NotNil(t, s.Repository, "Content repository must not be nil")
 					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				case cdevents.ChangeAbandonedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				case cdevents.ChangeUpdatedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
 				default:
 					require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", s))
 				}
@@ -282,6 +535,270 @@ func TestGiteaPullRequest(t *testing.T) {
 	}
 }
 
+func TestGiteaMergeCorrelation(t *testing.T) {
+
+	mergeSha := "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"
+	recordError := errors.New("connection closed")
+
+	pushPayload := func(ref string) string {
+		return `{
+			"ref": "` + ref + `",
+			"before": "a359287123178c5d05654864e80ab6f3bfc3d78a",
+			"after": "` + mergeSha + `",
+			"total_commits": 1,
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1",
+				"default_branch": "main"
+			}
+		}`
+	}
+
+	prMergedPayload := `{
+		"action": "closed",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR",
+			"merged": true,
+			"merge_commit_sha": "` + mergeSha + `"
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}`
+
+	justReceived := &jetstream.MsgMetadata{Timestamp: time.Now()}
+	receivedLongAgo := &jetstream.MsgMetadata{Timestamp: time.Now().Add(-time.Hour)}
+
+	for _, tc := range []struct {
+		title             string
+		translator        func(merges MergeRecorder) Webhook
+		payload           string
+		metadata          *jetstream.MsgMetadata
+		finalDelivery     bool
+		recorded          bool
+		lookupError       error
+		recordError       error
+		expectedLookup    bool
+		expectedRecord    bool
+		expectedEventType interface{}
+		expectedError     error
+	}{
+		{
+			title:             "push to merge branch is translated when merge not reported after wait",
+			translator:        func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges, MergeWait: time.Minute} },
+			payload:           pushPayload("refs/heads/main"),
+			metadata:          receivedLongAgo,
+			expectedLookup:    true,
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:          "push to merge branch is retried when merge not reported within wait",
+			translator:     func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges, MergeWait: time.Minute} },
+			payload:        pushPayload("refs/heads/main"),
+			metadata:       justReceived,
+			expectedLookup: true,
+			expectedError:  ErrTemporary,
+		},
+		{
+			title:             "push to merge branch is translated on final delivery when merge not reported within wait",
+			translator:        func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges, MergeWait: time.Minute} },
+			payload:           pushPayload("refs/heads/main"),
+			metadata:          justReceived,
+			finalDelivery:     true,
+			expectedLookup:    true,
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:          "push to merge branch is ignored on final delivery when merge reported by pull request",
+			translator:     func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges, MergeWait: time.Minute} },
+			payload:        pushPayload("refs/heads/main"),
+			metadata:       justReceived,
+			finalDelivery:  true,
+			recorded:       true,
+			expectedLookup: true,
+			expectedError:  ErrIgnored,
+		},
+		{
+			title:          "push to merge branch is ignored when merge reported by pull request",
+			translator:     func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges, MergeWait: time.Minute} },
+			payload:        pushPayload("refs/heads/main"),
+			metadata:       justReceived,
+			recorded:       true,
+			expectedLookup: true,
+			expectedError:  ErrIgnored,
+		},
+		{
+			title:          "push to merge branch is retried when merge cannot be looked up",
+			translator:     func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges} },
+			payload:        pushPayload("refs/heads/main"),
+			lookupError:    recordError,
+			expectedLookup: true,
+			expectedError:  ErrTemporary,
+		},
+		{
+			title:             "push to other branch is not looked up",
+			translator:        func(merges MergeRecorder) Webhook { return &GiteaPush{Merges: merges, MergeWait: time.Minute} },
+			payload:           pushPayload("refs/heads/foo"),
+			metadata:          justReceived,
+			expectedEventType: cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:             "merged pull request is translated and recorded",
+			translator:        func(merges MergeRecorder) Webhook { return &GiteaPullRequest{Merges: merges} },
+			payload:           prMergedPayload,
+			expectedRecord:    true,
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:             "merged pull request is translated when merge reported by push",
+			translator:        func(merges MergeRecorder) Webhook { return &GiteaPullRequest{Merges: merges} },
+			payload:           prMergedPayload,
+			recorded:          true,
+			expectedRecord:    true,
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:          "merged pull request is retried when merge cannot be recorded",
+			translator:     func(merges MergeRecorder) Webhook { return &GiteaPullRequest{Merges: merges} },
+			payload:        prMergedPayload,
+			recordError:    recordError,
+			expectedRecord: true,
+			expectedError:  ErrTemporary,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			mockMerges := &mockMergeRecorder{}
+			mockMerges.On("Recorded", mock.Anything, mock.Anything).Return(tc.recorded, tc.lookupError)
+			mockMerges.On("Record", mock.Anything, mock.Anything).Return(tc.recordError)
+
+			cdEvents, err := tc.translator(mockMerges).Translate(Request{Payload: []byte(tc.payload), Metadata: tc.metadata, FinalDelivery: tc.finalDelivery})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err, "no error should be returned when translating event")
+				require.NotNil(t, cdEvent, "CD event must not be nil")
+				assert.Equal(t, tc.expectedEventType, cdEvent.GetType(), "Event did not have expected type")
+			}
+
+			if tc.expectedLookup {
+				mockMerges.AssertCalled(t, "Recorded", "yoloco/project1", mergeSha)
+			} else {
+				mockMerges.AssertNotCalled(t, "Recorded", mock.Anything, mock.Anything)
+			}
+
+			if tc.expectedRecord {
+				mockMerges.AssertCalled(t, "Record", "yoloco/project1", mergeSha)
+			} else {
+				mockMerges.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGiteaPullRequestReview(t *testing.T) {
+
+	reviewPayload := func(reviewType string) string {
+		return `{
+			"action": "reviewed",
+			"pull_request": {
+				"id": 3,
+				"title": "Fix something PR"
+			},
+			"review": {
+				"type": "` + reviewType + `",
+				"content": "LGTM"
+			},
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			},
+			"sender": {
+				"login": "bertil"
+			}
+		}`
+	}
+
+	noReviewPayload := `{
+		"action": "reviewed",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR"
+		},
+		"review": null,
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		},
+		"sender": {
+			"login": "bertil"
+		}
+	}`
+
+	translator := &GiteaPullRequestReview{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedReviewState string
+		expectedError       error
+	}{
+		{
+			title:               "Return change reviewed event on approved review",
+			payload:             reviewPayload("pull_request_review_approved"),
+			expectedReviewState: "approved",
+		},
+		{
+			title:               "Return change reviewed event on rejected review",
+			payload:             reviewPayload("pull_request_review_rejected"),
+			expectedReviewState: "rejected",
+		},
+		{
+			title:               "Return change reviewed event on review comment",
+			payload:             reviewPayload("pull_request_review_comment"),
+			expectedReviewState: "comment",
+		},
+		{
+			title:         "Error on unknown review type",
+			payload:       reviewPayload("unknown"),
+			expectedError: ErrUnsupportedPRAction,
+		},
+		{
+			title:         "Error with no review in payload",
+			payload:       noReviewPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				return
+			}
+			require.NoError(t, err, "no error should be returned when translating event")
+
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+			assert.Equal(t, cdevents.ChangeReviewedEventTypeV0_2_0, cdEvent.GetType(), "Event must be of type ChangeReviewedEvent")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+			assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+			assert.Equal(t, "pr-3", cdEvent.GetSubjectId(), "Subject Id must be pr-<id>")
+
+			var customData struct {
+				Reviewer    string
+				ReviewState string
+			}
+			require.NoError(t, cdEvent.GetCustomDataAs(&customData), "custom data must be readable")
+			assert.Equal(t, "bertil", customData.Reviewer, "Reviewer must be the sender of the review")
+			assert.Equal(t, tc.expectedReviewState, customData.ReviewState, "Review state must be taken from review type")
+		})
+	}
+}
+
 func TestGiteaCreate(t *testing.T) {
 	branchCreatedPayload := `{
 		"ref": "foo",
@@ -334,7 +851,8 @@ func TestGiteaCreate(t *testing.T) {
 		},
 	} {
 		t.Run(tc.title, func(t *testing.T) {
-			cdEvent, err := translator.Translate([]byte(tc.payload))
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
 
 			if tc.expectedError != nil {
 				assert.Equal(t, tc.expectedError, err)
@@ -412,7 +930,8 @@ func TestGiteaDelete(t *testing.T) {
 		},
 	} {
 		t.Run(tc.title, func(t *testing.T) {
-			cdEvent, err := translator.Translate([]byte(tc.payload))
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
 
 			if tc.expectedError != nil {
 				assert.Equal(t, tc.expectedError, err)
@@ -437,3 +956,1222 @@ func TestGiteaDelete(t *testing.T) {
 		})
 	}
 }
+
+func TestGiteaTag(t *testing.T) {
+
+	tagSha := "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"
+
+	tagCreatedPayload := `{
+		"sha": "` + tagSha + `",
+		"ref": "v1.0.0",
+		"ref_type": "tag",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}`
+
+	tagDeletedPayload := `{
+		"ref": "v1.0.0",
+		"ref_type": "tag",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}`
+
+	tagPushPayload := func(before string, after string) string {
+		return `{
+			"ref": "refs/tags/v1.0.0",
+			"before": "` + before + `",
+			"after": "` + after + `",
+			"total_commits": 0,
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			}
+		}`
+	}
+
+	zeroSha := "0000000000000000000000000000000000000000"
+
+	for _, tc := range []struct {
+		title             string
+		translator        Webhook
+		payload           string
+		expectedEventType string
+		expectedSha       string
+		expectedError     error
+	}{
+		{
+			title:             "Returns tag created event on tag create payload",
+			translator:        &GiteaCreate{},
+			payload:           tagCreatedPayload,
+			expectedEventType: "dev.cdeventsx.git-tag.created.0.1.0",
+			expectedSha:       tagSha,
+		},
+		{
+			title:             "Returns tag deleted event on tag delete payload",
+			translator:        &GiteaDelete{},
+			payload:           tagDeletedPayload,
+			expectedEventType: "dev.cdeventsx.git-tag.deleted.0.1.0",
+		},
+		{
+			title:             "Returns tag created event on push of new tag",
+			translator:        &GiteaPush{},
+			payload:           tagPushPayload(zeroSha, tagSha),
+			expectedEventType: "dev.cdeventsx.git-tag.created.0.1.0",
+			expectedSha:       tagSha,
+		},
+		{
+			title:             "Returns tag deleted event on push removing tag",
+			translator:        &GiteaPush{},
+			payload:           tagPushPayload(tagSha, zeroSha),
+			expectedEventType: "dev.cdeventsx.git-tag.deleted.0.1.0",
+		},
+		{
+			title:         "Error on push of tag with missing after field",
+			translator:    &GiteaPush{},
+			payload:       tagPushPayload(zeroSha, ""),
+			expectedError: ErrMissingRequiredFields,
+		},
+		{
+			title:         "Ignores tag create payload when tags are reported by push",
+			translator:    &GiteaCreate{RefEvents: RefEventsFromPush},
+			payload:       tagCreatedPayload,
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Ignores tag delete payload when tags are reported by push",
+			translator:    &GiteaDelete{RefEvents: RefEventsFromPush},
+			payload:       tagDeletedPayload,
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Ignores push of new tag when tags are reported by create",
+			translator:    &GiteaPush{RefEvents: RefEventsFromCreate},
+			payload:       tagPushPayload(zeroSha, tagSha),
+			expectedError: ErrIgnored,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := tc.translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, tc.expectedEventType, cdEvent.GetType().String(), "Event must have expected type")
+			assert.Equal(t, "v1.0.0", cdEvent.GetSubjectId(), "Subject ID must be name of tag")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+			assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+
+			content, ok := cdEvent.GetSubjectContent().(TagSubjectContent)
+			require.True(t, ok, "failed to cast Subject Content")
+			require.NotNil(t, content.Repository, "Content repository must not be nil")
+			assert.Equal(t, "yoloco/project1", content.Repository.Id, "Content repository Id should be project full name")
+			assert.Equal(t, tc.expectedSha, content.Sha, "Content sha should be target of tag")
+
+			cloudEvent, err := cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "tag event must be valid")
+			assert.Equal(t, tc.expectedEventType, cloudEvent.Type(), "CloudEvent must have type of tag event")
+		})
+	}
+}
+
+func TestGiteaRelease(t *testing.T) {
+
+	releaseSha := "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"
+
+	releasePayload := func(action string, draft bool, targetCommitish string) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"release": {
+				"id": 4,
+				"tag_name": "v1.0.0",
+				"target_commitish": "%s",
+				"draft": %t,
+				"author": {
+					"login": "yoloco"
+				},
+				"assets": [
+					{
+						"id": 1,
+						"name": "project1-linux-amd64.tar.gz",
+						"browser_download_url": "http://git.example.com/yoloco/project1/releases/download/v1.0.0/project1-linux-amd64.tar.gz"
+					}
+				]
+			},
+			"repository": {
+				"name": "project1",
+				"owner": {
+					"username": "yoloco"
+				},
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			},
+			"sender": {
+				"login": "someone"
+			}
+		}`, action, targetCommitish, draft)
+	}
+
+	noTagNamePayload := `{
+		"action": "published",
+		"release": {
+			"id": 4
+		},
+		"repository": {
+			"name": "project1",
+			"owner": {
+				"username": "yoloco"
+			},
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}`
+
+	translator := &GiteaRelease{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedChange      string
+		expectedError       error
+	}{
+		{
+			title:               "Returns ArtifactPublishedEvent on published release",
+			payload:             releasePayload("published", false, "main"),
+			expectedCDEventType: &cdevents.ArtifactPublishedEventTypeV0_2_0,
+		},
+		{
+			title:         "Ignored on update of published release",
+			payload:       releasePayload("updated", false, "main"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:               "Returns ArtifactPackagedEvent with commit on draft release targeting commit",
+			payload:             releasePayload("published", true, releaseSha),
+			expectedCDEventType: &cdevents.ArtifactPackagedEventTypeV0_2_0,
+			expectedChange:      releaseSha,
+		},
+		{
+			title:               "Returns ArtifactPackagedEvent with commit on update of draft release targeting commit",
+			payload:             releasePayload("updated", true, releaseSha),
+			expectedCDEventType: &cdevents.ArtifactPackagedEventTypeV0_2_0,
+			expectedChange:      releaseSha,
+		},
+		{
+			title:         "Ignored on draft release targeting branch",
+			payload:       releasePayload("published", true, "main"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:               "Returns ArtifactDeletedEvent on deleted release",
+			payload:             releasePayload("deleted", false, "main"),
+			expectedCDEventType: &cdevents.ArtifactDeletedEventTypeV0_1_0,
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       releasePayload("unknown", false, "main"),
+			expectedError: ErrUnsupportedAction,
+		},
+		{
+			title:         "Error when payload is missing tag name",
+			payload:       noTagNamePayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, "pkg:generic/yoloco/project1@v1.0.0?vcs_url=git+http:%2F%2Fgit.example.com%2Fyoloco%2Fproject1", cdEvent.GetSubjectId(), "Subject ID must be package URL of release")
+			if content, ok := cdEvent.GetSubjectContent().(cdevents.ArtifactPackagedSubjectContentV0_2_0); ok {
+				require.NotNil(t, content.Change, "Content change must not be nil")
+				assert.Equal(t, tc.expectedChange, content.Change.Id, "Content change must be commit of release")
+			}
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+			assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+
+			var customData struct {
+				Assets []struct {
+					Name string
+				}
+			}
+			require.NoError(t, cdEvent.GetCustomDataAs(&customData), "custom data must be readable")
+			require.Len(t, customData.Assets, 1, "custom data must list release assets")
+			assert.Equal(t, "project1-linux-amd64.tar.gz", customData.Assets[0].Name)
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaPackage(t *testing.T) {
+
+	packagePayload := func(action string, packageType string, name string, version string) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"package": {
+				"id": 7,
+				"owner": {
+					"login": "yoloco"
+				},
+				"creator": {
+					"login": "someone"
+				},
+				"type": "%s",
+				"name": "%s",
+				"version": "%s",
+				"html_url": "http://git.example.com/yoloco/-/packages/%[2]s/%[3]s/%[4]s"
+			},
+			"sender": {
+				"login": "someone"
+			}
+		}`, action, packageType, name, version)
+	}
+
+	translator := &GiteaPackage{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedSubjectId   string
+		expectedError       error
+	}{
+		{
+			title:               "Returns ArtifactPublishedEvent with OCI package URL for container image tag",
+			payload:             packagePayload("created", "container", "project1", "1.0.0"),
+			expectedCDEventType: &cdevents.ArtifactPublishedEventTypeV0_2_0,
+			expectedSubjectId:   "pkg:oci/project1?repository_url=git.example.com%2Fyoloco%2Fproject1&tag=1.0.0",
+		},
+		{
+			title:               "Returns ArtifactPublishedEvent with OCI package URL for container image digest",
+			payload:             packagePayload("created", "container", "project1", "sha256:244fd47e07d10"),
+			expectedCDEventType: &cdevents.ArtifactPublishedEventTypeV0_2_0,
+			expectedSubjectId:   "pkg:oci/project1@sha256:244fd47e07d10?repository_url=git.example.com%2Fyoloco%2Fproject1",
+		},
+		{
+			title:               "Returns ArtifactPublishedEvent with npm package URL for scoped package",
+			payload:             packagePayload("created", "npm", "@yoloco/lib", "2.1.0"),
+			expectedCDEventType: &cdevents.ArtifactPublishedEventTypeV0_2_0,
+			expectedSubjectId:   "pkg:npm/%40yoloco/lib@2.1.0?repository_url=git.example.com%2Fapi%2Fpackages%2Fyoloco%2Fnpm",
+		},
+		{
+			title:               "Returns ArtifactPublishedEvent with golang package URL for Go module",
+			payload:             packagePayload("created", "go", "git.example.com/yoloco/project1", "v1.0.0"),
+			expectedCDEventType: &cdevents.ArtifactPublishedEventTypeV0_2_0,
+			expectedSubjectId:   "pkg:golang/git.example.com/yoloco/project1@v1.0.0",
+		},
+		{
+			title:               "Returns ArtifactPublishedEvent with generic package URL for other package types",
+			payload:             packagePayload("created", "generic", "tool", "1.0.0"),
+			expectedCDEventType: &cdevents.ArtifactPublishedEventTypeV0_2_0,
+			expectedSubjectId:   "pkg:generic/yoloco/tool@1.0.0?package_type=generic&repository_url=git.example.com%2Fapi%2Fpackages%2Fyoloco%2Fgeneric",
+		},
+		{
+			title:               "Returns ArtifactDeletedEvent on deleted package",
+			payload:             packagePayload("deleted", "go", "git.example.com/yoloco/project1", "v1.0.0"),
+			expectedCDEventType: &cdevents.ArtifactDeletedEventTypeV0_1_0,
+			expectedSubjectId:   "pkg:golang/git.example.com/yoloco/project1@v1.0.0",
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       packagePayload("unknown", "go", "git.example.com/yoloco/project1", "v1.0.0"),
+			expectedError: ErrUnsupportedAction,
+		},
+		{
+			title:         "Error when payload is missing version",
+			payload:       packagePayload("created", "go", "git.example.com/yoloco/project1", ""),
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, tc.expectedSubjectId, cdEvent.GetSubjectId(), "Subject ID must be package URL of package")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaWorkflowRun(t *testing.T) {
+
+	workflowRunPayload := func(action string, conclusion string) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"workflow": {
+				"id": 2,
+				"name": "build",
+				"path": ".gitea/workflows/build.yaml"
+			},
+			"workflow_run": {
+				"id": 42,
+				"name": "build",
+				"head_branch": "main",
+				"head_sha": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+				"status": "completed",
+				"conclusion": "%s",
+				"html_url": "http://git.example.com/yoloco/project1/actions/runs/42"
+			},
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			}
+		}`, action, conclusion)
+	}
+
+	noRunIdPayload := `{
+		"action": "requested",
+		"workflow": {
+			"name": "build"
+		},
+		"workflow_run": {
+			"name": "build"
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}`
+
+	translator := &GiteaWorkflowRun{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedOutcome     string
+		expectedErrors      string
+		expectedError       error
+	}{
+		{
+			title:               "Returns PipelineRunQueuedEvent on requested workflow run",
+			payload:             workflowRunPayload("requested", ""),
+			expectedCDEventType: &cdevents.PipelineRunQueuedEventTypeV0_2_0,
+		},
+		{
+			title:               "Returns PipelineRunStartedEvent on workflow run in progress",
+			payload:             workflowRunPayload("in_progress", ""),
+			expectedCDEventType: &cdevents.PipelineRunStartedEventTypeV0_2_0,
+		},
+		{
+			title:               "Returns PipelineRunFinishedEvent with success outcome on successful workflow run",
+			payload:             workflowRunPayload("completed", "success"),
+			expectedCDEventType: &cdevents.PipelineRunFinishedEventTypeV0_2_0,
+			expectedOutcome:     "success",
+		},
+		{
+			title:               "Returns PipelineRunFinishedEvent with failure outcome on failed workflow run",
+			payload:             workflowRunPayload("completed", "failure"),
+			expectedCDEventType: &cdevents.PipelineRunFinishedEventTypeV0_2_0,
+			expectedOutcome:     "failure",
+		},
+		{
+			title:               "Returns PipelineRunFinishedEvent with error outcome on cancelled workflow run",
+			payload:             workflowRunPayload("completed", "cancelled"),
+			expectedCDEventType: &cdevents.PipelineRunFinishedEventTypeV0_2_0,
+			expectedOutcome:     "error",
+			expectedErrors:      "Workflow concluded as cancelled",
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       workflowRunPayload("unknown", ""),
+			expectedError: ErrUnsupportedAction,
+		},
+		{
+			title:         "Error when payload is missing workflow run id",
+			payload:       noRunIdPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, "42", cdEvent.GetSubjectId(), "Subject ID must be id of workflow run")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+			assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+
+			switch content := cdEvent.GetSubjectContent().(type) {
+			case cdevents.PipelineRunQueuedSubjectContentV0_2_0:
+				assert.Equal(t, "build", content.PipelineName)
+				assert.Equal(t, "http://git.example.com/yoloco/project1/actions/runs/42", content.Url)
+			case cdevents.PipelineRunStartedSubjectContentV0_2_0:
+				assert.Equal(t, "build", content.PipelineName)
+				assert.Equal(t, "http://git.example.com/yoloco/project1/actions/runs/42", content.Url)
+			case cdevents.PipelineRunFinishedSubjectContentV0_2_0:
+				assert.Equal(t, "build", content.PipelineName)
+				assert.Equal(t, tc.expectedOutcome, content.Outcome)
+				assert.Equal(t, tc.expectedErrors, content.Errors)
+			default:
+				require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", content))
+			}
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaWorkflowJob(t *testing.T) {
+
+	workflowJobPayload := func(action string, conclusion string) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"workflow_job": {
+				"id": 108,
+				"run_id": 42,
+				"name": "test",
+				"status": "completed",
+				"conclusion": "%s",
+				"html_url": "http://git.example.com/yoloco/project1/actions/runs/42/jobs/0"
+			},
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			}
+		}`, action, conclusion)
+	}
+
+	translator := &GiteaWorkflowJob{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedOutcome     string
+		expectedError       error
+	}{
+		{
+			title:         "Ignores queued workflow job",
+			payload:       workflowJobPayload("queued", ""),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:               "Returns TaskRunStartedEvent on workflow job in progress",
+			payload:             workflowJobPayload("in_progress", ""),
+			expectedCDEventType: &cdevents.TaskRunStartedEventTypeV0_2_0,
+		},
+		{
+			title:               "Returns TaskRunFinishedEvent with outcome on completed workflow job",
+			payload:             workflowJobPayload("completed", "failure"),
+			expectedCDEventType: &cdevents.TaskRunFinishedEventTypeV0_2_0,
+			expectedOutcome:     "failure",
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       workflowJobPayload("unknown", ""),
+			expectedError: ErrUnsupportedAction,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, "108", cdEvent.GetSubjectId(), "Subject ID must be id of workflow job")
+
+			switch content := cdEvent.GetSubjectContent().(type) {
+			case cdevents.TaskRunStartedSubjectContentV0_2_0:
+				assert.Equal(t, "test", content.TaskName)
+				require.NotNil(t, content.PipelineRun, "Content pipeline run must not be nil")
+				assert.Equal(t, "42", content.PipelineRun.Id)
+			case cdevents.TaskRunFinishedSubjectContentV0_2_0:
+				assert.Equal(t, "test", content.TaskName)
+				require.NotNil(t, content.PipelineRun, "Content pipeline run must not be nil")
+				assert.Equal(t, "42", content.PipelineRun.Id)
+				assert.Equal(t, tc.expectedOutcome, content.Outcome)
+			default:
+				require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", content))
+			}
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaIssues(t *testing.T) {
+
+	issuePayload := func(action string, labels string) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"number": 12,
+			"issue": {
+				"id": 31,
+				"number": 12,
+				"html_url": "http://git.example.com/yoloco/project1/issues/12",
+				"title": "Follow up on incident",
+				"user": {
+					"login": "yoloco"
+				},
+				"labels": [%s],
+				"milestone": {
+					"id": 1,
+					"title": "v1.1.0"
+				},
+				"assignees": [
+					{
+						"login": "someone"
+					}
+				],
+				"state": "open"
+			},
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			},
+			"sender": {
+				"login": "someone"
+			}
+		}`, action, labels)
+	}
+
+	noIssuePayload := `{
+		"action": "opened",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "http://git.example.com/yoloco/project1"
+		}
+	}`
+
+	translator := &GiteaIssues{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedLabels      []string
+		expectedPriority    string
+		expectedResolution  string
+		expectedError       error
+	}{
+		{
+			title:               "Returns TicketCreatedEvent on opened issue",
+			payload:             issuePayload("opened", `{"name": "bug"}, {"name": "priority/high"}`),
+			expectedCDEventType: &cdevents.TicketCreatedEventTypeV0_1_0,
+			expectedLabels:      []string{"bug", "priority/high"},
+			expectedPriority:    "high",
+		},
+		{
+			title:               "Returns TicketUpdatedEvent on labels changed",
+			payload:             issuePayload("label_updated", `{"name": "bug"}`),
+			expectedCDEventType: &cdevents.TicketUpdatedEventTypeV0_1_0,
+			expectedLabels:      []string{"bug"},
+		},
+		{
+			title:               "Returns TicketUpdatedEvent on assigned issue",
+			payload:             issuePayload("assigned", ""),
+			expectedCDEventType: &cdevents.TicketUpdatedEventTypeV0_1_0,
+		},
+		{
+			title:               "Returns TicketClosedEvent with completed resolution on closed issue",
+			payload:             issuePayload("closed", `{"name": "bug"}`),
+			expectedCDEventType: &cdevents.TicketClosedEventTypeV0_1_0,
+			expectedLabels:      []string{"bug"},
+			expectedResolution:  "completed",
+		},
+		{
+			title:               "Returns TicketClosedEvent with duplicate resolution on closed duplicate issue",
+			payload:             issuePayload("closed", `{"name": "Duplicate"}`),
+			expectedCDEventType: &cdevents.TicketClosedEventTypeV0_1_0,
+			expectedLabels:      []string{"Duplicate"},
+			expectedResolution:  "duplicate",
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       issuePayload("unknown", ""),
+			expectedError: ErrUnsupportedAction,
+		},
+		{
+			title:         "Error when payload is missing issue",
+			payload:       noIssuePayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, "issue-31", cdEvent.GetSubjectId(), "Subject ID must be id of issue")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+			assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+
+			var content struct {
+				Summary    string   `json:"summary"`
+				Group      string   `json:"group"`
+				Creator    string   `json:"creator"`
+				Assignees  []string `json:"assignees"`
+				Labels     []string `json:"labels"`
+				Priority   string   `json:"priority"`
+				Milestone  string   `json:"milestone"`
+				Resolution string   `json:"resolution"`
+				Uri        string   `json:"uri"`
+			}
+			contentJson, err := json.Marshal(cdEvent.GetSubjectContent())
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(contentJson, &content))
+
+			assert.Equal(t, "Follow up on incident", content.Summary)
+			assert.Equal(t, "yoloco/project1", content.Group)
+			assert.Equal(t, "yoloco", content.Creator)
+			assert.Equal(t, []string{"someone"}, content.Assignees)
+			assert.Equal(t, tc.expectedLabels, content.Labels)
+			assert.Equal(t, tc.expectedPriority, content.Priority)
+			assert.Equal(t, "v1.1.0", content.Milestone)
+			assert.Equal(t, tc.expectedResolution, content.Resolution)
+			assert.Equal(t, "http://git.example.com/yoloco/project1/issues/12", content.Uri)
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaIssueComment(t *testing.T) {
+
+	commentPayload := func(action string, isPull bool) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"issue": {
+				"id": 31,
+				"number": 12,
+				"html_url": "http://git.example.com/yoloco/project1/issues/12",
+				"title": "Follow up on incident",
+				"user": {
+					"login": "yoloco"
+				}
+			},
+			"comment": {
+				"id": 5,
+				"body": "Looking into it"
+			},
+			"is_pull": %t,
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			},
+			"sender": {
+				"login": "someone"
+			}
+		}`, action, isPull)
+	}
+
+	translator := &GiteaIssueComment{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedError       error
+	}{
+		{
+			title:               "Returns TicketUpdatedEvent on comment created on issue",
+			payload:             commentPayload("created", false),
+			expectedCDEventType: &cdevents.TicketUpdatedEventTypeV0_1_0,
+		},
+		{
+			title:         "Ignores comment on pull request",
+			payload:       commentPayload("created", true),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       commentPayload("unknown", false),
+			expectedError: ErrUnsupportedAction,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, "issue-31", cdEvent.GetSubjectId(), "Subject ID must be id of issue")
+
+			if content, ok := cdEvent.GetSubjectContent().(cdevents.TicketUpdatedSubjectContentV0_1_0); ok {
+				assert.Equal(t, "someone", content.UpdatedBy, "Ticket must be updated by commenter")
+			} else {
+				require.Fail(t, "failed to cast Subject Content")
+			}
+		})
+	}
+}
+
+func TestGiteaRepository(t *testing.T) {
+
+	repositoryPayload := func(action string) string {
+		return fmt.Sprintf(`{
+			"action": "%s",
+			"repository": {
+				"name": "project1",
+				"owner": {
+					"username": "yoloco"
+				},
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1",
+				"clone_url": "http://git.example.com/yoloco/project1.git"
+			},
+			"organization": {
+				"id": 3,
+				"username": "yoloco"
+			},
+			"sender": {
+				"login": "yoloco"
+			}
+		}`, action)
+	}
+
+	noRepoPayload := `{
+		"action": "created"
+	}`
+
+	translator := &GiteaRepository{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedError       error
+	}{
+		{
+			title:               "Returns RepositoryCreatedEvent on created repository",
+			payload:             repositoryPayload("created"),
+			expectedCDEventType: &cdevents.RepositoryCreatedEventTypeV0_2_0,
+		},
+		{
+			title:               "Returns RepositoryDeletedEvent on deleted repository",
+			payload:             repositoryPayload("deleted"),
+			expectedCDEventType: &cdevents.RepositoryDeletedEventTypeV0_2_0,
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       repositoryPayload("unknown"),
+			expectedError: ErrUnsupportedAction,
+		},
+		{
+			title:         "Error when payload is missing repository",
+			payload:       noRepoPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, "yoloco/project1", cdEvent.GetSubjectId(), "Subject ID must be full name of repository")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+			assert.Equal(t, "git.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+
+			var content struct {
+				Name    string `json:"name"`
+				Owner   string `json:"owner"`
+				Url     string `json:"url"`
+				ViewUrl string `json:"viewUrl"`
+			}
+			contentJson, err := json.Marshal(cdEvent.GetSubjectContent())
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(contentJson, &content))
+
+			assert.Equal(t, "project1", content.Name)
+			assert.Equal(t, "yoloco", content.Owner)
+			assert.Equal(t, "http://git.example.com/yoloco/project1.git", content.Url)
+			assert.Equal(t, "http://git.example.com/yoloco/project1", content.ViewUrl)
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaStatus(t *testing.T) {
+
+	statusPayload := func(context string, state string) string {
+		return fmt.Sprintf(`{
+			"id": 17,
+			"sha": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			"context": "%s",
+			"description": "Build #12 %s",
+			"state": "%[2]s",
+			"target_url": "https://ci.example.com/job/project1/12",
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "http://git.example.com/yoloco/project1"
+			}
+		}`, context, state)
+	}
+
+	statusPolicy := StatusPolicy{Rules: []StatusRule{
+		{Pattern: "ci/test-*", Kind: StatusKindTestSuite},
+		{Pattern: "ci/lint", Kind: StatusKindIgnore},
+	}}
+
+	translator := &GiteaStatus{StatusPolicy: statusPolicy}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedSubjectId   string
+		expectedOutcome     string
+		expectedError       error
+	}{
+		{
+			title:               "Returns PipelineRunFinishedEvent on successful status not matching any rule",
+			payload:             statusPayload("ci/build", "success"),
+			expectedCDEventType: &cdevents.PipelineRunFinishedEventTypeV0_2_0,
+			expectedSubjectId:   "ci/build@9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			expectedOutcome:     "success",
+		},
+		{
+			title:               "Returns PipelineRunFinishedEvent with failure outcome on failed status",
+			payload:             statusPayload("ci/build", "failure"),
+			expectedCDEventType: &cdevents.PipelineRunFinishedEventTypeV0_2_0,
+			expectedSubjectId:   "ci/build@9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			expectedOutcome:     "failure",
+		},
+		{
+			title:               "Returns TestSuiteRunFinishedEvent on status matching test suite rule",
+			payload:             statusPayload("ci/test-unit", "failure"),
+			expectedCDEventType: &cdevents.TestSuiteRunFinishedEventTypeV0_2_0,
+			expectedSubjectId:   "ci/test-unit@9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			expectedOutcome:     "fail",
+		},
+		{
+			title:               "Returns TestSuiteRunFinishedEvent with error outcome on errored status",
+			payload:             statusPayload("ci/test-unit", "error"),
+			expectedCDEventType: &cdevents.TestSuiteRunFinishedEventTypeV0_2_0,
+			expectedSubjectId:   "ci/test-unit@9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			expectedOutcome:     "error",
+		},
+		{
+			title:         "Ignores pending status",
+			payload:       statusPayload("ci/build", "pending"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Ignores status matching ignore rule",
+			payload:       statusPayload("ci/lint", "success"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Error when payload is missing context",
+			payload:       statusPayload("", "success"),
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.NotNil(t, cdEvent, "CD event must not be nil")
+
+			assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+			assert.Equal(t, tc.expectedSubjectId, cdEvent.GetSubjectId(), "Subject ID must be status context at commit")
+			assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+
+			switch content := cdEvent.GetSubjectContent().(type) {
+			case cdevents.PipelineRunFinishedSubjectContentV0_2_0:
+				assert.Equal(t, tc.expectedOutcome, content.Outcome)
+				assert.Equal(t, "https://ci.example.com/job/project1/12", content.Url)
+			case cdevents.TestSuiteRunFinishedSubjectContentV0_2_0:
+				assert.Equal(t, tc.expectedOutcome, content.Outcome)
+				require.NotNil(t, content.TestSuite, "Content test suite must not be nil")
+				assert.Equal(t, "https://ci.example.com/job/project1/12", content.TestSuite.Uri)
+			default:
+				require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", content))
+			}
+
+			_, err = cdevents.AsCloudEvent(cdEvent)
+			require.NoError(t, err, "event must be valid")
+		})
+	}
+}
+
+func TestGiteaTimestamps(t *testing.T) {
+
+	for _, tc := range []struct {
+		title             string
+		translator        Webhook
+		payload           string
+		expectedTimestamp time.Time
+	}{
+		{
+			title:      "push is dated by head commit",
+			translator: &GiteaPush{},
+			payload: `{
+				"ref": "refs/heads/main",
+				"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+				"total_commits": 1,
+				"head_commit": {"id": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", "timestamp": "2025-02-12T10:00:00+01:00"},
+				"repository": {"full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1", "default_branch": "main"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "merged pull request is dated by merge",
+			translator: &GiteaPullRequest{},
+			payload: `{
+				"action": "closed",
+				"pull_request": {"id": 1, "merged": true, "created_at": "2025-02-10T08:00:00Z", "closed_at": "2025-02-12T09:00:01Z", "merged_at": "2025-02-12T09:00:00Z"},
+				"repository": {"full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "abandoned pull request is dated by close",
+			translator: &GiteaPullRequest{},
+			payload: `{
+				"action": "closed",
+				"pull_request": {"id": 1, "merged": false, "created_at": "2025-02-10T08:00:00Z", "closed_at": "2025-02-12T09:00:01Z", "merged_at": null},
+				"repository": {"full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 1, 0, time.UTC),
+		},
+		{
+			title:      "opened pull request is dated by creation",
+			translator: &GiteaPullRequest{},
+			payload: `{
+				"action": "opened",
+				"pull_request": {"id": 1, "title": "Add foo", "created_at": "2025-02-10T08:00:00Z"},
+				"repository": {"full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 10, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "review is dated by update of pull request",
+			translator: &GiteaPullRequestReview{},
+			payload: `{
+				"action": "reviewed",
+				"pull_request": {"id": 1, "created_at": "2025-02-10T08:00:00Z", "updated_at": "2025-02-12T09:00:00Z"},
+				"review": {"type": "pull_request_review_approved"},
+				"sender": {"login": "reviewer"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "published release is dated by publication",
+			translator: &GiteaRelease{},
+			payload: `{
+				"action": "published",
+				"release": {"tag_name": "v1.0.0", "created_at": "2025-02-10T08:00:00Z", "published_at": "2025-02-12T09:00:00Z", "author": {"login": "yoloco"}},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "draft release is dated by creation",
+			translator: &GiteaRelease{},
+			payload: `{
+				"action": "published",
+				"release": {"tag_name": "v1.0.0", "target_commitish": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", "draft": true, "created_at": "2025-02-10T08:00:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 10, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "created package is dated by creation",
+			translator: &GiteaPackage{},
+			payload: `{
+				"action": "created",
+				"package": {"type": "generic", "name": "foo", "version": "1.0.0", "html_url": "http://git.example.com/yoloco/-/packages/generic/foo/1.0.0", "owner": {"login": "yoloco"}, "creator": {"login": "yoloco"}, "created_at": "2025-02-12T09:00:00Z"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "requested workflow run is dated by creation",
+			translator: &GiteaWorkflowRun{},
+			payload: `{
+				"action": "requested",
+				"workflow": {"name": "CI"},
+				"workflow_run": {"id": 42, "created_at": "2025-02-12T09:00:00Z", "updated_at": "2025-02-12T09:00:05Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "started workflow run is dated by start",
+			translator: &GiteaWorkflowRun{},
+			payload: `{
+				"action": "in_progress",
+				"workflow": {"name": "CI"},
+				"workflow_run": {"id": 42, "created_at": "2025-02-12T09:00:00Z", "updated_at": "2025-02-12T09:00:05Z", "run_started_at": "2025-02-12T09:00:01Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 1, 0, time.UTC),
+		},
+		{
+			title:      "completed workflow run is dated by completion",
+			translator: &GiteaWorkflowRun{},
+			payload: `{
+				"action": "completed",
+				"workflow": {"name": "CI"},
+				"workflow_run": {"id": 42, "conclusion": "success", "created_at": "2025-02-12T09:00:00Z", "updated_at": "2025-02-12T09:05:01Z", "run_started_at": "2025-02-12T09:00:01Z", "completed_at": "2025-02-12T09:05:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 5, 0, 0, time.UTC),
+		},
+		{
+			title:      "started workflow job is dated by start",
+			translator: &GiteaWorkflowJob{},
+			payload: `{
+				"action": "in_progress",
+				"workflow_job": {"id": 7, "run_id": 42, "name": "build", "created_at": "2025-02-12T09:00:00Z", "started_at": "2025-02-12T09:00:01Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 1, 0, time.UTC),
+		},
+		{
+			title:      "completed workflow job is dated by completion",
+			translator: &GiteaWorkflowJob{},
+			payload: `{
+				"action": "completed",
+				"workflow_job": {"id": 7, "run_id": 42, "name": "build", "conclusion": "success", "created_at": "2025-02-12T09:00:00Z", "started_at": "2025-02-12T09:00:01Z", "completed_at": "2025-02-12T09:05:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 5, 0, 0, time.UTC),
+		},
+		{
+			title:      "opened issue is dated by creation",
+			translator: &GiteaIssues{},
+			payload: `{
+				"action": "opened",
+				"issue": {"id": 3, "html_url": "http://git.example.com/yoloco/project1/issues/1", "created_at": "2025-02-10T08:00:00Z", "updated_at": "2025-02-12T09:00:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 10, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "closed issue is dated by close",
+			translator: &GiteaIssues{},
+			payload: `{
+				"action": "closed",
+				"issue": {"id": 3, "html_url": "http://git.example.com/yoloco/project1/issues/1", "created_at": "2025-02-10T08:00:00Z", "updated_at": "2025-02-12T09:00:01Z", "closed_at": "2025-02-12T09:00:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "edited issue is dated by update",
+			translator: &GiteaIssues{},
+			payload: `{
+				"action": "edited",
+				"issue": {"id": 3, "html_url": "http://git.example.com/yoloco/project1/issues/1", "created_at": "2025-02-10T08:00:00Z", "updated_at": "2025-02-12T09:00:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "issue comment is dated by creation of comment",
+			translator: &GiteaIssueComment{},
+			payload: `{
+				"action": "created",
+				"issue": {"id": 3, "html_url": "http://git.example.com/yoloco/project1/issues/1", "created_at": "2025-02-10T08:00:00Z", "updated_at": "2025-02-12T09:00:01Z"},
+				"comment": {"id": 5, "created_at": "2025-02-12T09:00:00Z", "updated_at": "2025-02-12T09:00:00Z"},
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:      "commit status is dated by update",
+			translator: &GiteaStatus{},
+			payload: `{
+				"sha": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+				"context": "ci/build",
+				"state": "success",
+				"created_at": "2025-02-12T09:00:00Z",
+				"updated_at": "2025-02-12T09:05:00Z",
+				"repository": {"name": "project1", "owner": {"username": "yoloco"}, "full_name": "yoloco/project1", "html_url": "http://git.example.com/yoloco/project1"}
+			}`,
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 5, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := tc.translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+			require.NoError(t, err, "no error should be returned")
+			assert.True(t, tc.expectedTimestamp.Equal(cdEvent.GetTimestamp()), "expected timestamp %s, got %s", tc.expectedTimestamp, cdEvent.GetTimestamp())
+		})
+	}
+}
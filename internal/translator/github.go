@@ -0,0 +1,240 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/structs"
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+)
+
+// GitHubPush translates pushes to the merge branches given by the branch
+// policy into ChangeMerged events, and pushes to other branches into
+// ChangeUpdated events unless the policy says that they should be ignored.
+type GitHubPush struct {
+	BranchPolicy BranchPolicy
+}
+
+func (g *GitHubPush) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var githubEvent structs.GitHubPushEvent
+	if err := json.Unmarshal(req.Payload, &githubEvent); err != nil {
+		return nil, err
+	}
+
+	if githubEvent.Repository.FullName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+	repository := &cdevents.Reference{Id: githubEvent.Repository.FullName}
+
+	if len(githubEvent.Commits) == 0 {
+		return nil, Ignore(ReasonNoCommits, "push to %s has no new commits", githubEvent.Ref)
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	branch, isBranch := strings.CutPrefix(githubEvent.Ref, "refs/heads/")
+	switch {
+	case isBranch && g.BranchPolicy.IsMergeBranch(githubEvent.Repository.FullName, githubEvent.Repository.DefaultBranch, branch):
+		changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeMergedEvent.SetSubjectRepository(repository)
+		cdEvent = changeMergedEvent
+	case g.BranchPolicy.IgnoreOtherBranches:
+		return nil, Ignore(ReasonPolicy, "push to %s is not to a merge branch", githubEvent.Ref)
+	default:
+		changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeUpdatedEvent.SetSubjectRepository(repository)
+		cdEvent = changeUpdatedEvent
+	}
+
+	if err := addSourcesFromRepositoryUrl(githubEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if githubEvent.After == "" {
+		return nil, ErrMissingRequiredFields
+	}
+	cdEvent.SetSubjectId(githubEvent.After)
+	setTimestampFromPayload(cdEvent, githubEvent.HeadCommit.Timestamp)
+
+	if err := addWebhookEventAsCustomData(kindGitHubPush, githubEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+// githubPullRequestIgnoredActions are the pull request actions that there are
+// no events for.
+var githubPullRequestIgnoredActions = []string{
+	"assigned", "unassigned", "labeled", "unlabeled", "converted_to_draft", "ready_for_review", "locked", "unlocked", "milestoned", "demilestoned",
+	"review_requested", "review_request_removed", "auto_merge_enabled", "auto_merge_disabled",
+	"enqueued", "dequeued",
+}
+
+// githubIgnoredRefTypes are the ref types of created and deleted refs that
+// there are no events for.
+var githubIgnoredRefTypes = []string{"tag"}
+
+type GitHubPullRequest struct{}
+
+func (g *GitHubPullRequest) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var githubEvent structs.GitHubPullRequestEvent
+	if err := json.Unmarshal(req.Payload, &githubEvent); err != nil {
+		return nil, err
+	}
+
+	if githubEvent.Repository.FullName == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	if githubEvent.Action == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	switch githubEvent.Action {
+	case "opened":
+		changeCreatedEvent, err := cdeventsv04.NewChangeCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeCreatedEvent.SetSubjectRepository(&cdevents.Reference{Id: githubEvent.Repository.FullName})
+		if githubEvent.PullRequest.Title == "" {
+			return nil, ErrMissingRequiredFields
+		}
+		changeCreatedEvent.SetSubjectDescription(githubEvent.PullRequest.Title)
+		cdEvent = changeCreatedEvent
+	case "closed":
+		if githubEvent.PullRequest.Merged {
+			changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+			if err != nil {
+				return nil, err
+			}
+			changeMergedEvent.SetSubjectRepository(&cdevents.Reference{Id: githubEvent.Repository.FullName})
+			cdEvent = changeMergedEvent
+		} else {
+			changeAbandonedEvent, err := cdeventsv04.NewChangeAbandonedEvent()
+			if err != nil {
+				return nil, err
+			}
+			changeAbandonedEvent.SetSubjectRepository(&cdevents.Reference{Id: githubEvent.Repository.FullName})
+			cdEvent = changeAbandonedEvent
+		}
+	case "reopened", "synchronize", "edited":
+		// As for Gitea, a reopened PR continues the lifecycle of the change
+		// that was created when it was first opened.
+		changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeUpdatedEvent.SetSubjectRepository(&cdevents.Reference{Id: githubEvent.Repository.FullName})
+		cdEvent = changeUpdatedEvent
+	default:
+		return nil, ignoreKnown(ReasonUnsupportedAction, githubEvent.Action, githubPullRequestIgnoredActions, ErrUnsupportedPRAction)
+	}
+
+	if err := addSourcesFromRepositoryUrl(githubEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if githubEvent.PullRequest.Id == 0 {
+		return nil, ErrMissingRequiredFields
+	}
+	cdEvent.SetSubjectId(fmt.Sprintf("pr-%d", githubEvent.PullRequest.Id))
+	setTimestampFromPayload(cdEvent, pullRequestTimestamps(githubEvent.Action, githubEvent.PullRequest.CreatedAt, githubEvent.PullRequest.UpdatedAt, githubEvent.PullRequest.ClosedAt, githubEvent.PullRequest.MergedAt)...)
+
+	if err := addWebhookEventAsCustomData(kindGitHubPullRequest, githubEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+type GitHubCreate struct{}
+
+func (g *GitHubCreate) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var githubEvent structs.GitHubCreateEvent
+	if err := json.Unmarshal(req.Payload, &githubEvent); err != nil {
+		return nil, err
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch githubEvent.RefType {
+	case "branch":
+		branchCreatedEvent, err := cdeventsv04.NewBranchCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchCreatedEvent.SetSubjectRepository(&cdevents.Reference{Id: githubEvent.Repository.FullName})
+		cdEvent = branchCreatedEvent
+	default:
+		return nil, ignoreKnown(ReasonUnsupportedRef, githubEvent.RefType, githubIgnoredRefTypes, ErrUnsupportedRefType)
+	}
+
+	if err := addSourcesFromRepositoryUrl(githubEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if githubEvent.Ref == "" {
+		return nil, ErrMissingRequiredFields
+	}
+	cdEvent.SetSubjectId(githubEvent.Ref)
+
+	if err := addWebhookEventAsCustomData(kindGitHubCreate, githubEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
+
+type GitHubDelete struct{}
+
+func (g *GitHubDelete) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var githubEvent structs.GitHubDeleteEvent
+	if err := json.Unmarshal(req.Payload, &githubEvent); err != nil {
+		return nil, err
+	}
+
+	var cdEvent cdevents.CDEvent
+
+	switch githubEvent.RefType {
+	case "branch":
+		branchDeletedEvent, err := cdeventsv04.NewBranchDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchDeletedEvent.SetSubjectRepository(&cdevents.Reference{Id: githubEvent.Repository.FullName})
+		cdEvent = branchDeletedEvent
+	default:
+		return nil, ignoreKnown(ReasonUnsupportedRef, githubEvent.RefType, githubIgnoredRefTypes, ErrUnsupportedRefType)
+	}
+
+	if err := addSourcesFromRepositoryUrl(githubEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if githubEvent.Ref == "" {
+		return nil, ErrMissingRequiredFields
+	}
+	cdEvent.SetSubjectId(githubEvent.Ref)
+
+	if err := addWebhookEventAsCustomData(kindGitHubDelete, githubEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
@@ -0,0 +1,412 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubPush(t *testing.T) {
+
+	pushCommitPayload := func(branch string) string {
+		return `{
+			"ref": "refs/heads/` + branch + `",
+			"before": "a359287123178c5d05654864e80ab6f3bfc3d78a",
+			"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			"commits": [
+				{
+					"id": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+					"message": "Update README.md"
+				}
+			],
+			"repository": {
+				"full_name": "yoloco/project1",
+				"html_url": "https://github.com/yoloco/project1",
+				"default_branch": "main"
+			}
+		}`
+	}
+
+	pushNewBranchPayload := `{
+		"ref": "refs/heads/foo",
+		"before": "0000000000000000000000000000000000000000",
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"created": true,
+		"commits": [],
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	repoWithNoHtmlUrlPayload := `{
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"commits": [{"id": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"}],
+		"repository": {
+			"full_name": "yoloco/project1"
+		}
+	}`
+
+	repoWithNoFullNamePayload := `{
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"commits": [{"id": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2"}],
+		"repository": {
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	for _, tc := range []struct {
+		title             string
+		branchPolicy      BranchPolicy
+		payload           string
+		expectedEventType interface{}
+		expectedError     error
+	}{
+		{
+			title:             "returns ChangeMergedEvent on push to default branch payload",
+			payload:           pushCommitPayload("main"),
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:             "returns ChangeUpdatedEvent on push to other branch payload",
+			payload:           pushCommitPayload("foo"),
+			expectedEventType: cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:             "returns ChangeMergedEvent on push to merge branch of policy",
+			branchPolicy:      BranchPolicy{Patterns: map[string][]string{"*": {"release/*"}}},
+			payload:           pushCommitPayload("release/1.0"),
+			expectedEventType: cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:         "ignored on push to other branch when policy ignores other branches",
+			branchPolicy:  BranchPolicy{IgnoreOtherBranches: true},
+			payload:       pushCommitPayload("foo"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "ignored on push to new branch with no new commits",
+			payload:       pushNewBranchPayload,
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "error when payload missing repository HTML url field",
+			payload:       repoWithNoHtmlUrlPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+		{
+			title:         "error when payload missing repository full name field",
+			payload:       repoWithNoFullNamePayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			translator := &GitHubPush{BranchPolicy: tc.branchPolicy}
+
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err, "no error should be returned when translating event")
+			}
+
+			if tc.expectedEventType != nil {
+				require.NotNil(t, cdEvent, "CD event must not be nil")
+
+				assert.Equal(t, tc.expectedEventType, cdEvent.GetType(), "Event did not have expected type")
+				assert.Equal(t, "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", cdEvent.GetSubjectId(), "Subject ID must be commit sha")
+				assert.Equal(t, "github.com", cdEvent.GetSource(), "Event Source must be server host name")
+				assert.Equal(t, "github.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+
+				subjectContent := cdEvent.GetSubjectContent()
+				switch s := subjectContent.(type) {
+				case cdevents.ChangeMergedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				case cdevents.ChangeUpdatedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				default:
+					require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", s))
+				}
+			}
+		})
+	}
+}
+
+func TestGitHubPullRequest(t *testing.T) {
+
+	prOpenedPayload := `{
+		"action": "opened",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR"
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	prMergedPayload := `{
+		"action": "closed",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR",
+			"merged": true
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	prClosedPayload := `{
+		"action": "closed",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR",
+			"merged": false
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	prWithUnsupportedAction := `{
+		"action": "unknown",
+		"pull_request": {
+			"id": 3,
+			"title": "Fix something PR"
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	prWithNoIdPayload := `{
+		"action": "closed",
+		"pull_request": {
+			"title": "Fix something PR"
+		},
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	translator := &GitHubPullRequest{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedError       error
+	}{
+		{
+			title:               "Return change created event on PR opened payload",
+			payload:             prOpenedPayload,
+			expectedCDEventType: &cdevents.ChangeCreatedEventTypeV0_3_0,
+		},
+		{
+			title:               "Return change merged event on merged PR closed payload",
+			payload:             prMergedPayload,
+			expectedCDEventType: &cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change abandoned event on unmerged PR closed payload",
+			payload:             prClosedPayload,
+			expectedCDEventType: &cdevents.ChangeAbandonedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change updated event on PR synchronize payload",
+			payload:             strings.Replace(prWithUnsupportedAction, `"unknown"`, `"synchronize"`, 1),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change updated event on PR edited payload",
+			payload:             strings.Replace(prWithUnsupportedAction, `"unknown"`, `"edited"`, 1),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change updated event on PR reopened payload",
+			payload:             strings.Replace(prWithUnsupportedAction, `"unknown"`, `"reopened"`, 1),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:         "Ignored on PR labeled payload",
+			payload:       strings.Replace(prWithUnsupportedAction, `"unknown"`, `"labeled"`, 1),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       prWithUnsupportedAction,
+			expectedError: ErrUnsupportedPRAction,
+		},
+		{
+			title:         "Error with no pull_request id field in payload",
+			payload:       prWithNoIdPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err, "no error should be returned when translating event")
+			}
+
+			if tc.expectedCDEventType != nil {
+				require.NotNil(t, cdEvent, "CD event must not be nil")
+				assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+				assert.Equal(t, "github.com", cdEvent.GetSource(), "Event Source must be server host name")
+				assert.Equal(t, "github.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+				assert.Equal(t, "pr-3", cdEvent.GetSubjectId(), "Subject Id must be pr-<id>")
+
+				subjectContent := cdEvent.GetSubjectContent()
+				switch s := subjectContent.(type) {
+				case cdevents.ChangeCreatedSubjectContentV0_3_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+					assert.Equal(t, "Fix something PR", s.Description, "Description must be PR title")
+				case cdevents.ChangeMergedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				case cdevents.ChangeAbandonedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				case cdevents.ChangeUpdatedSubjectContentV0_2_0:
+					require.NotNil(t, s.Repository, "Content repository must not be nil")
+					assert.Equal(t, "yoloco/project1", s.Repository.Id, "Content repository Id should be project full name")
+				default:
+					require.Fail(t, fmt.Sprintf("unexpected subject content type: %T", s))
+				}
+			}
+		})
+	}
+}
+
+func TestGitHubCreateAndDelete(t *testing.T) {
+	branchPayload := `{
+		"ref": "foo",
+		"ref_type": "branch",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	tagPayload := `{
+		"ref": "v1.0.0",
+		"ref_type": "tag",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	unknownRefTypePayload := `{
+		"ref": "v1.0.0",
+		"ref_type": "unknown",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	noRefFieldPayload := `{
+		"ref_type": "branch",
+		"repository": {
+			"full_name": "yoloco/project1",
+			"html_url": "https://github.com/yoloco/project1"
+		}
+	}`
+
+	for _, tc := range []struct {
+		title               string
+		translator          Webhook
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedError       error
+	}{
+		{
+			title:               "Create returns BranchCreatedEvent",
+			translator:          &GitHubCreate{},
+			payload:             branchPayload,
+			expectedCDEventType: &cdevents.BranchCreatedEventTypeV0_2_0,
+		},
+		{
+			title:         "Create errors when payload is missing ref field",
+			translator:    &GitHubCreate{},
+			payload:       noRefFieldPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+		{
+			title:         "Create ignores tags",
+			translator:    &GitHubCreate{},
+			payload:       tagPayload,
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Create errors with unsupported ref type",
+			translator:    &GitHubCreate{},
+			payload:       unknownRefTypePayload,
+			expectedError: ErrUnsupportedRefType,
+		},
+		{
+			title:               "Delete returns BranchDeletedEvent",
+			translator:          &GitHubDelete{},
+			payload:             branchPayload,
+			expectedCDEventType: &cdevents.BranchDeletedEventTypeV0_2_0,
+		},
+		{
+			title:         "Delete errors when payload is missing ref field",
+			translator:    &GitHubDelete{},
+			payload:       noRefFieldPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+		{
+			title:         "Delete ignores tags",
+			translator:    &GitHubDelete{},
+			payload:       tagPayload,
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Delete errors with unsupported ref type",
+			translator:    &GitHubDelete{},
+			payload:       unknownRefTypePayload,
+			expectedError: ErrUnsupportedRefType,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := tc.translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err, "no error should be returned when translating event")
+			}
+
+			if tc.expectedCDEventType != nil {
+				require.NotNil(t, cdEvent, "CD event must not be nil")
+				assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+				assert.Equal(t, "foo", cdEvent.GetSubjectId(), "Subject ID must be name of ref")
+				assert.Equal(t, "github.com", cdEvent.GetSource(), "Event Source must be server host name")
+				assert.Equal(t, "github.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package translator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/structs"
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+)
+
+const gitlabZeroSha = "0000000000000000000000000000000000000000"
+
+// GitLabPush translates both push and tag push hooks. As GitLab has no
+// separate hooks for creating and deleting branches, pushes that create or
+// delete a branch become branch events, and tag pushes become the custom tag
+// events. The commits of a push, including those of a push that creates a
+// branch, are translated into a ChangeMerged event when they are to the merge
+// branches given by the branch policy, and into a ChangeUpdated event unless
+// the policy says that they should be ignored.
+type GitLabPush struct {
+	BranchPolicy BranchPolicy
+}
+
+func (g *GitLabPush) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var gitlabEvent structs.GitLabPushEvent
+	if err := json.Unmarshal(req.Payload, &gitlabEvent); err != nil {
+		return nil, err
+	}
+
+	if gitlabEvent.Project.PathWithNamespace == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if tag, isTag := strings.CutPrefix(gitlabEvent.Ref, "refs/tags/"); isTag {
+		cdEvent, err := g.translateTagPush(gitlabEvent, tag)
+		if err != nil {
+			return nil, err
+		}
+		return []cdevents.CDEvent{cdEvent}, nil
+	}
+
+	branch, isBranch := strings.CutPrefix(gitlabEvent.Ref, "refs/heads/")
+	if !isBranch {
+		return nil, ErrUnsupportedRefType
+	}
+
+	if branch == "" || gitlabEvent.After == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvents []cdevents.CDEvent
+
+	if gitlabEvent.Before == gitlabZeroSha || gitlabEvent.After == gitlabZeroSha {
+		branchEvent, err := g.translateBranchPush(gitlabEvent, branch)
+		if err != nil {
+			return nil, err
+		}
+		cdEvents = append(cdEvents, branchEvent)
+	}
+
+	if gitlabEvent.After == gitlabZeroSha || gitlabEvent.TotalCommitsCount == 0 {
+		if len(cdEvents) == 0 {
+			return nil, Ignore(ReasonNoCommits, "push to %s has no new commits", gitlabEvent.Ref)
+		}
+		return cdEvents, nil
+	}
+
+	changeEvent, err := g.translateCommitPush(gitlabEvent, branch)
+	if errors.Is(err, ErrIgnored) && len(cdEvents) > 0 {
+		return cdEvents, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append(cdEvents, changeEvent), nil
+}
+
+// translateBranchPush translates a push creating or deleting a branch into a
+// BranchCreated or BranchDeleted event.
+func (g *GitLabPush) translateBranchPush(gitlabEvent structs.GitLabPushEvent, branch string) (cdevents.CDEvent, error) {
+
+	repository := &cdevents.Reference{Id: gitlabEvent.Project.PathWithNamespace}
+
+	var cdEvent cdevents.CDEvent
+
+	if gitlabEvent.After == gitlabZeroSha {
+		branchDeletedEvent, err := cdeventsv04.NewBranchDeletedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchDeletedEvent.SetSubjectRepository(repository)
+		cdEvent = branchDeletedEvent
+	} else {
+		branchCreatedEvent, err := cdeventsv04.NewBranchCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		branchCreatedEvent.SetSubjectRepository(repository)
+		cdEvent = branchCreatedEvent
+	}
+
+	if err := addSourcesFromRepositoryUrl(gitlabEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	cdEvent.SetSubjectId(branch)
+
+	if err := addWebhookEventAsCustomData(kindGitLabPush, gitlabEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return cdEvent, nil
+}
+
+// translateCommitPush translates the commits pushed to the branch into a
+// ChangeMerged or ChangeUpdated event depending on the branch policy.
+func (g *GitLabPush) translateCommitPush(gitlabEvent structs.GitLabPushEvent, branch string) (cdevents.CDEvent, error) {
+
+	repository := &cdevents.Reference{Id: gitlabEvent.Project.PathWithNamespace}
+
+	var cdEvent cdevents.CDEvent
+
+	switch {
+	case g.BranchPolicy.IsMergeBranch(gitlabEvent.Project.PathWithNamespace, gitlabEvent.Project.DefaultBranch, branch):
+		changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeMergedEvent.SetSubjectRepository(repository)
+		cdEvent = changeMergedEvent
+	case g.BranchPolicy.IgnoreOtherBranches:
+		return nil, Ignore(ReasonPolicy, "push to %s is not to a merge branch", gitlabEvent.Ref)
+	default:
+		changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeUpdatedEvent.SetSubjectRepository(repository)
+		cdEvent = changeUpdatedEvent
+	}
+
+	if err := addSourcesFromRepositoryUrl(gitlabEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	cdEvent.SetSubjectId(gitlabEvent.After)
+	for _, commit := range gitlabEvent.Commits {
+		if commit.Id == gitlabEvent.After {
+			setTimestampFromPayload(cdEvent, commit.Timestamp)
+			break
+		}
+	}
+
+	if err := addWebhookEventAsCustomData(kindGitLabPush, gitlabEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return cdEvent, nil
+}
+
+// translateTagPush translates a tag push into a tag created event with the
+// commit that the tag points to, or a tag deleted event if the tag was removed.
+func (g *GitLabPush) translateTagPush(gitlabEvent structs.GitLabPushEvent, tag string) (cdevents.CDEvent, error) {
+
+	if tag == "" || gitlabEvent.After == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	var cdEvent cdevents.CDEvent
+	var err error
+	if gitlabEvent.After == gitlabZeroSha {
+		cdEvent, err = newTagEvent(TagDeletedEventType, gitlabEvent.Project.PathWithNamespace, tag, "")
+	} else {
+		// The after sha of an annotated tag is that of the tag object, while
+		// the checkout sha is that of the commit it points to.
+		sha := gitlabEvent.CheckoutSha
+		if sha == "" {
+			sha = gitlabEvent.After
+		}
+		cdEvent, err = newTagEvent(TagCreatedEventType, gitlabEvent.Project.PathWithNamespace, tag, sha)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := addSourcesFromRepositoryUrl(gitlabEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if err := addWebhookEventAsCustomData(kindGitLabPush, gitlabEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return cdEvent, nil
+}
+
+// gitlabMergeRequestIgnoredActions are the merge request actions that there
+// are no events for.
+var gitlabMergeRequestIgnoredActions = []string{"reopen", "approved", "unapproved", "approval", "unapproval"}
+
+type GitLabMergeRequest struct{}
+
+func (g *GitLabMergeRequest) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var gitlabEvent structs.GitLabMergeRequestEvent
+	if err := json.Unmarshal(req.Payload, &gitlabEvent); err != nil {
+		return nil, err
+	}
+
+	if gitlabEvent.Project.PathWithNamespace == "" {
+		return nil, ErrMissingRequiredFields
+	}
+
+	repository := &cdevents.Reference{Id: gitlabEvent.Project.PathWithNamespace}
+
+	var cdEvent cdevents.CDEvent
+
+	switch gitlabEvent.ObjectAttributes.Action {
+	case "":
+		return nil, ErrMissingRequiredFields
+	case "open":
+		changeCreatedEvent, err := cdeventsv04.NewChangeCreatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeCreatedEvent.SetSubjectRepository(repository)
+		if gitlabEvent.ObjectAttributes.Title == "" {
+			return nil, ErrMissingRequiredFields
+		}
+		changeCreatedEvent.SetSubjectDescription(gitlabEvent.ObjectAttributes.Title)
+		cdEvent = changeCreatedEvent
+	case "update":
+		changeUpdatedEvent, err := cdeventsv04.NewChangeUpdatedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeUpdatedEvent.SetSubjectRepository(repository)
+		cdEvent = changeUpdatedEvent
+	case "merge":
+		changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeMergedEvent.SetSubjectRepository(repository)
+		cdEvent = changeMergedEvent
+	case "close":
+		changeAbandonedEvent, err := cdeventsv04.NewChangeAbandonedEvent()
+		if err != nil {
+			return nil, err
+		}
+		changeAbandonedEvent.SetSubjectRepository(repository)
+		cdEvent = changeAbandonedEvent
+	default:
+		return nil, ignoreKnown(ReasonUnsupportedAction, gitlabEvent.ObjectAttributes.Action, gitlabMergeRequestIgnoredActions, ErrUnsupportedPRAction)
+	}
+
+	if err := addSourcesFromRepositoryUrl(gitlabEvent, cdEvent); err != nil {
+		return nil, ErrMissingRequiredFields
+	}
+
+	if gitlabEvent.ObjectAttributes.Id == 0 {
+		return nil, ErrMissingRequiredFields
+	}
+	cdEvent.SetSubjectId(fmt.Sprintf("mr-%d", gitlabEvent.ObjectAttributes.Id))
+	if gitlabEvent.ObjectAttributes.Action == "open" {
+		setTimestampFromPayload(cdEvent, gitlabEvent.ObjectAttributes.CreatedAt)
+	} else {
+		setTimestampFromPayload(cdEvent, gitlabEvent.ObjectAttributes.UpdatedAt)
+	}
+
+	if err := addWebhookEventAsCustomData(kindGitLabMergeRequest, gitlabEvent, cdEvent); err != nil {
+		return nil, err
+	}
+
+	return []cdevents.CDEvent{cdEvent}, nil
+}
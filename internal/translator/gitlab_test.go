@@ -0,0 +1,343 @@
+package translator
+
+import (
+	"testing"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitLabPush(t *testing.T) {
+
+	pushCommitPayload := func(branch string) string {
+		return `{
+			"object_kind": "push",
+			"before": "a359287123178c5d05654864e80ab6f3bfc3d78a",
+			"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			"ref": "refs/heads/` + branch + `",
+			"total_commits_count": 1,
+			"project": {
+				"path_with_namespace": "yoloco/project1",
+				"web_url": "https://gitlab.example.com/yoloco/project1",
+				"default_branch": "main"
+			}
+		}`
+	}
+
+	pushNewBranchPayload := `{
+		"object_kind": "push",
+		"before": "0000000000000000000000000000000000000000",
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"ref": "refs/heads/foo",
+		"total_commits_count": 0,
+		"project": {
+			"path_with_namespace": "yoloco/project1",
+			"web_url": "https://gitlab.example.com/yoloco/project1"
+		}
+	}`
+
+	pushNewBranchWithCommitsPayload := func(branch string) string {
+		return `{
+			"object_kind": "push",
+			"before": "0000000000000000000000000000000000000000",
+			"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+			"ref": "refs/heads/` + branch + `",
+			"total_commits_count": 1,
+			"project": {
+				"path_with_namespace": "yoloco/project1",
+				"web_url": "https://gitlab.example.com/yoloco/project1",
+				"default_branch": "main"
+			}
+		}`
+	}
+
+	pushDeleteBranchPayload := `{
+		"object_kind": "push",
+		"before": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"after": "0000000000000000000000000000000000000000",
+		"ref": "refs/heads/foo",
+		"total_commits_count": 0,
+		"project": {
+			"path_with_namespace": "yoloco/project1",
+			"web_url": "https://gitlab.example.com/yoloco/project1"
+		}
+	}`
+
+	pushNoCommitsPayload := `{
+		"object_kind": "push",
+		"before": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"ref": "refs/heads/main",
+		"total_commits_count": 0,
+		"project": {
+			"path_with_namespace": "yoloco/project1",
+			"web_url": "https://gitlab.example.com/yoloco/project1"
+		}
+	}`
+
+	tagPushPayload := `{
+		"object_kind": "tag_push",
+		"before": "0000000000000000000000000000000000000000",
+		"after": "82b3d5ae55f7080f1e6022629cdb57bfae7cccc7",
+		"checkout_sha": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"ref": "refs/tags/v1.0.0",
+		"project": {
+			"path_with_namespace": "yoloco/project1",
+			"web_url": "https://gitlab.example.com/yoloco/project1"
+		}
+	}`
+
+	tagDeletePayload := `{
+		"object_kind": "tag_push",
+		"before": "82b3d5ae55f7080f1e6022629cdb57bfae7cccc7",
+		"after": "0000000000000000000000000000000000000000",
+		"ref": "refs/tags/v1.0.0",
+		"project": {
+			"path_with_namespace": "yoloco/project1",
+			"web_url": "https://gitlab.example.com/yoloco/project1"
+		}
+	}`
+
+	noProjectUrlPayload := `{
+		"object_kind": "push",
+		"before": "a359287123178c5d05654864e80ab6f3bfc3d78a",
+		"after": "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		"ref": "refs/heads/main",
+		"total_commits_count": 1,
+		"project": {
+			"path_with_namespace": "yoloco/project1"
+		}
+	}`
+
+	for _, tc := range []struct {
+		title               string
+		branchPolicy        BranchPolicy
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedSubjectId   string
+		// expectedChangeEventType is the type of the event for the commits of
+		// a push that also creates a branch.
+		expectedChangeEventType *cdevents.CDEventType
+		expectedError           error
+	}{
+		{
+			title:               "Returns ChangeMergedEvent on push with commits to default branch",
+			payload:             pushCommitPayload("main"),
+			expectedCDEventType: &cdevents.ChangeMergedEventTypeV0_2_0,
+			expectedSubjectId:   "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		},
+		{
+			title:               "Returns ChangeUpdatedEvent on push with commits to other branch",
+			payload:             pushCommitPayload("foo"),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+			expectedSubjectId:   "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		},
+		{
+			title:               "Returns ChangeMergedEvent on push with commits to merge branch of policy",
+			branchPolicy:        BranchPolicy{Patterns: map[string][]string{"yoloco/project1": {"release/*"}}},
+			payload:             pushCommitPayload("release/1.0"),
+			expectedCDEventType: &cdevents.ChangeMergedEventTypeV0_2_0,
+			expectedSubjectId:   "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2",
+		},
+		{
+			title:         "Ignored on push to other branch when policy ignores other branches",
+			branchPolicy:  BranchPolicy{IgnoreOtherBranches: true},
+			payload:       pushCommitPayload("foo"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:               "Returns BranchCreatedEvent on push of new branch",
+			payload:             pushNewBranchPayload,
+			expectedCDEventType: &cdevents.BranchCreatedEventTypeV0_2_0,
+			expectedSubjectId:   "foo",
+		},
+		{
+			title:                   "Returns BranchCreatedEvent and ChangeUpdatedEvent on push of new branch with commits",
+			payload:                 pushNewBranchWithCommitsPayload("foo"),
+			expectedCDEventType:     &cdevents.BranchCreatedEventTypeV0_2_0,
+			expectedSubjectId:       "foo",
+			expectedChangeEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:                   "Returns BranchCreatedEvent and ChangeMergedEvent on push of new merge branch with commits",
+			branchPolicy:            BranchPolicy{Patterns: map[string][]string{"*": {"release/*"}}},
+			payload:                 pushNewBranchWithCommitsPayload("release/1.0"),
+			expectedCDEventType:     &cdevents.BranchCreatedEventTypeV0_2_0,
+			expectedSubjectId:       "release/1.0",
+			expectedChangeEventType: &cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:               "Returns only BranchCreatedEvent on push of new branch with commits when policy ignores other branches",
+			branchPolicy:        BranchPolicy{IgnoreOtherBranches: true},
+			payload:             pushNewBranchWithCommitsPayload("foo"),
+			expectedCDEventType: &cdevents.BranchCreatedEventTypeV0_2_0,
+			expectedSubjectId:   "foo",
+		},
+		{
+			title:               "Returns BranchDeletedEvent on push deleting branch",
+			payload:             pushDeleteBranchPayload,
+			expectedCDEventType: &cdevents.BranchDeletedEventTypeV0_2_0,
+			expectedSubjectId:   "foo",
+		},
+		{
+			title:         "Ignored on push with no new commits",
+			payload:       pushNoCommitsPayload,
+			expectedError: ErrIgnored,
+		},
+		{
+			title:               "Returns TagCreated event on tag push",
+			payload:             tagPushPayload,
+			expectedCDEventType: &TagCreatedEventType,
+			expectedSubjectId:   "v1.0.0",
+		},
+		{
+			title:               "Returns TagDeleted event on tag push deleting tag",
+			payload:             tagDeletePayload,
+			expectedCDEventType: &TagDeletedEventType,
+			expectedSubjectId:   "v1.0.0",
+		},
+		{
+			title:         "Error when payload is missing project web url",
+			payload:       noProjectUrlPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			translator := &GitLabPush{BranchPolicy: tc.branchPolicy}
+
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err, "no error should be returned when translating event")
+			}
+
+			if tc.expectedChangeEventType != nil {
+				require.Len(t, cdEvents, 2, "branch and change events should be translated")
+				changeEvent := cdEvents[1]
+				assert.Equal(t, *tc.expectedChangeEventType, changeEvent.GetType(), "Change event must have expected type")
+				assert.Equal(t, "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", changeEvent.GetSubjectId(), "Change event must have the pushed sha as subject id")
+				assert.Equal(t, "gitlab.example.com/yoloco/project1", changeEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+				cdEvents = cdEvents[:1]
+			}
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedCDEventType != nil {
+				require.NotNil(t, cdEvent, "CD event must not be nil")
+				assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+				assert.Equal(t, tc.expectedSubjectId, cdEvent.GetSubjectId(), "Event must have expected subject id")
+				assert.Equal(t, "gitlab.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+				assert.Equal(t, "gitlab.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+			}
+
+			if tc.expectedCDEventType == &TagCreatedEventType {
+				content, ok := cdEvent.GetSubjectContent().(TagSubjectContent)
+				require.True(t, ok, "failed to cast Subject Content")
+				assert.Equal(t, "9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", content.Sha, "Tag must point to the checked out commit")
+			}
+		})
+	}
+}
+
+func TestGitLabMergeRequest(t *testing.T) {
+
+	mergeRequestPayload := func(action string) string {
+		return `{
+			"object_kind": "merge_request",
+			"object_attributes": {
+				"id": 99,
+				"iid": 1,
+				"title": "Fix something MR",
+				"action": "` + action + `"
+			},
+			"project": {
+				"path_with_namespace": "yoloco/project1",
+				"web_url": "https://gitlab.example.com/yoloco/project1"
+			}
+		}`
+	}
+
+	noIdPayload := `{
+		"object_kind": "merge_request",
+		"object_attributes": {
+			"title": "Fix something MR",
+			"action": "merge"
+		},
+		"project": {
+			"path_with_namespace": "yoloco/project1",
+			"web_url": "https://gitlab.example.com/yoloco/project1"
+		}
+	}`
+
+	translator := &GitLabMergeRequest{}
+
+	for _, tc := range []struct {
+		title               string
+		payload             string
+		expectedCDEventType *cdevents.CDEventType
+		expectedError       error
+	}{
+		{
+			title:               "Return change created event on open action",
+			payload:             mergeRequestPayload("open"),
+			expectedCDEventType: &cdevents.ChangeCreatedEventTypeV0_3_0,
+		},
+		{
+			title:               "Return change updated event on update action",
+			payload:             mergeRequestPayload("update"),
+			expectedCDEventType: &cdevents.ChangeUpdatedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change merged event on merge action",
+			payload:             mergeRequestPayload("merge"),
+			expectedCDEventType: &cdevents.ChangeMergedEventTypeV0_2_0,
+		},
+		{
+			title:               "Return change abandoned event on close action",
+			payload:             mergeRequestPayload("close"),
+			expectedCDEventType: &cdevents.ChangeAbandonedEventTypeV0_2_0,
+		},
+		{
+			title:         "Ignored on approved action",
+			payload:       mergeRequestPayload("approved"),
+			expectedError: ErrIgnored,
+		},
+		{
+			title:         "Error on unsupported action",
+			payload:       mergeRequestPayload("unknown"),
+			expectedError: ErrUnsupportedPRAction,
+		},
+		{
+			title:         "Error with no action",
+			payload:       mergeRequestPayload(""),
+			expectedError: ErrMissingRequiredFields,
+		},
+		{
+			title:         "Error with no merge request id",
+			payload:       noIdPayload,
+			expectedError: ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload)})
+			cdEvent := singleEvent(t, cdEvents)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err, "no error should be returned when translating event")
+			}
+
+			if tc.expectedCDEventType != nil {
+				require.NotNil(t, cdEvent, "CD event must not be nil")
+				assert.Equal(t, *tc.expectedCDEventType, cdEvent.GetType(), "Event must have expected type")
+				assert.Equal(t, "mr-99", cdEvent.GetSubjectId(), "Subject Id must be mr-<id>")
+				assert.Equal(t, "gitlab.example.com", cdEvent.GetSource(), "Event Source must be server host name")
+				assert.Equal(t, "gitlab.example.com/yoloco/project1", cdEvent.GetSubjectSource(), "Event Subject Source must be URL to project")
+			}
+		})
+	}
+}
@@ -0,0 +1,507 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"gopkg.in/yaml.v3"
+)
+
+// mappingFile is a file of declarative translators, in YAML or JSON.
+type mappingFile struct {
+	Mappings []mapping `yaml:"mappings"`
+}
+
+// mapping translates webhooks on the subject that match all predicates into a
+// CDEvent of the type, e.g. "service.deployed", and spec version. The fields of
+// the event are expressions on the webhook payload.
+type mapping struct {
+	Subject        string                 `yaml:"subject"`
+	Match          []predicate            `yaml:"match"`
+	Type           string                 `yaml:"type"`
+	SpecVersion    string                 `yaml:"specVersion"`
+	Source         string                 `yaml:"source"`
+	SubjectId      string                 `yaml:"subjectId"`
+	SubjectSource  string                 `yaml:"subjectSource"`
+	Timestamp      string                 `yaml:"timestamp"`
+	SubjectContent map[string]interface{} `yaml:"subjectContent"`
+	CustomData     interface{}            `yaml:"customData"`
+}
+
+// predicate holds for payloads where the value at the JSONPath equals the
+// given value, is one of the given values, exists or not, or matches the
+// regular expression.
+type predicate struct {
+	Path    string        `yaml:"path"`
+	Equals  interface{}   `yaml:"equals"`
+	In      []interface{} `yaml:"in"`
+	Exists  *bool         `yaml:"exists"`
+	Matches string        `yaml:"matches"`
+}
+
+// LoadMappingFiles loads the declarative translators of the mapping files,
+// keyed by the subject that they translate webhooks of. Mappings for the same
+// subject are combined into one translator.
+func LoadMappingFiles(paths []string) (map[string]Webhook, error) {
+	translators := map[string]*mappingWebhook{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var file mappingFile
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse mapping file %s: %w", path, err)
+		}
+
+		for i, m := range file.Mappings {
+			rule, err := compileMapping(m)
+			if err != nil {
+				return nil, fmt.Errorf("invalid mapping %d in %s: %w", i, path, err)
+			}
+			if translators[m.Subject] == nil {
+				translators[m.Subject] = &mappingWebhook{}
+			}
+			translators[m.Subject].rules = append(translators[m.Subject].rules, rule)
+		}
+	}
+
+	webhooks := make(map[string]Webhook, len(translators))
+	for subject, translator := range translators {
+		webhooks[subject] = translator
+	}
+	return webhooks, nil
+}
+
+// mappingWebhook translates webhooks into an event for each of the mappings
+// that match them.
+type mappingWebhook struct {
+	rules []*mappingRule
+}
+
+func (m *mappingWebhook) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	var payload interface{}
+	decoder := json.NewDecoder(bytes.NewReader(req.Payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	var cdEvents []cdevents.CDEvent
+	for _, rule := range m.rules {
+		if !rule.matches(payload) {
+			continue
+		}
+		cdEvent, err := rule.translate(payload)
+		if err != nil {
+			return nil, err
+		}
+		cdEvents = append(cdEvents, cdEvent)
+	}
+
+	if len(cdEvents) == 0 {
+		return nil, Ignore(ReasonNoMatch, "no mapping matches webhook on %s", req.Subject)
+	}
+
+	return cdEvents, nil
+}
+
+type mappingRule struct {
+	eventType      string
+	specVersion    string
+	predicates     []compiledPredicate
+	source         *expression
+	subjectId      *expression
+	subjectSource  *expression
+	timestamp      *expression
+	subjectContent interface{}
+	customData     interface{}
+}
+
+type compiledPredicate struct {
+	path    *expression
+	equals  interface{}
+	in      []interface{}
+	exists  *bool
+	matches *regexp.Regexp
+}
+
+func compileMapping(m mapping) (*mappingRule, error) {
+	if m.Subject == "" || m.Type == "" {
+		return nil, fmt.Errorf("subject and type are required")
+	}
+	if m.Source == "" || m.SubjectId == "" {
+		return nil, fmt.Errorf("source and subjectId are required")
+	}
+
+	rule := &mappingRule{specVersion: m.SpecVersion}
+	if rule.specVersion == "" {
+		rule.specVersion = cdeventsv04.SpecVersion
+	}
+
+	cdEvent, err := newEvent("dev.cdevents."+m.Type, rule.specVersion)
+	if err != nil {
+		return nil, err
+	}
+	rule.eventType = cdEvent.GetType().UnversionedString()
+
+	for _, p := range m.Match {
+		compiled := compiledPredicate{equals: p.Equals, in: p.In, exists: p.Exists}
+		if compiled.path, err = compileJSONPath(p.Path); err != nil {
+			return nil, err
+		}
+		if p.Matches != "" {
+			if compiled.matches, err = regexp.Compile(p.Matches); err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", p.Matches, err)
+			}
+		}
+		rule.predicates = append(rule.predicates, compiled)
+	}
+
+	for _, field := range []struct {
+		value  string
+		target **expression
+	}{
+		{m.Source, &rule.source},
+		{m.SubjectId, &rule.subjectId},
+		{m.SubjectSource, &rule.subjectSource},
+		{m.Timestamp, &rule.timestamp},
+	} {
+		if field.value == "" {
+			continue
+		}
+		if *field.target, err = compileExpression(field.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.subjectContent, err = compileValue(m.SubjectContent); err != nil {
+		return nil, err
+	}
+	if rule.customData, err = compileValue(m.CustomData); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (r *mappingRule) matches(payload interface{}) bool {
+	for _, p := range r.predicates {
+		value, err := p.path.evaluate(payload)
+		exists := err == nil && value != nil
+
+		switch {
+		case p.exists != nil && *p.exists != exists:
+			return false
+		case p.equals != nil && (!exists || !equalValues(value, p.equals)):
+			return false
+		case p.matches != nil && (!exists || !p.matches.MatchString(fmt.Sprint(value))):
+			return false
+		case p.in != nil:
+			found := false
+			for _, candidate := range p.in {
+				found = found || (exists && equalValues(value, candidate))
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r *mappingRule) translate(payload interface{}) (cdevents.CDEvent, error) {
+
+	source, err := evaluateString(r.source, payload)
+	if err != nil {
+		return nil, err
+	}
+	subjectId, err := evaluateString(r.subjectId, payload)
+	if err != nil {
+		return nil, err
+	}
+	if source == "" || subjectId == "" {
+		return nil, ErrMissingRequiredFields
+	}
+	subjectSource, err := evaluateString(r.subjectSource, payload)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := evaluateString(r.timestamp, payload)
+	if err != nil {
+		return nil, err
+	}
+	subjectContent, err := evaluateValue(r.subjectContent, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	cdEvent, err := newEvent(r.eventType, r.specVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	// The SDK has no way of setting the subject content of events generically,
+	// so it is set on the JSON of the event, which is read back into the event.
+	if content, ok := subjectContent.(map[string]interface{}); ok && len(content) > 0 {
+		if cdEvent, err = withSubjectContent(cdEvent, r.specVersion, content); err != nil {
+			return nil, err
+		}
+	}
+
+	cdEvent.SetSource(source)
+	cdEvent.SetSubjectId(subjectId)
+	if subjectSource != "" {
+		cdEvent.SetSubjectSource(subjectSource)
+	}
+	setTimestampFromPayload(cdEvent, timestamp)
+
+	if r.customData != nil {
+		customData, err := evaluateValue(r.customData, payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := setCustomData(customData, cdEvent); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cdevents.Validate(cdEvent); err != nil {
+		return nil, fmt.Errorf("mapped event is invalid: %w", err)
+	}
+
+	return cdEvent, nil
+}
+
+// withSubjectContent returns a copy of the event with the fields of the subject
+// content set.
+func withSubjectContent(cdEvent cdevents.CDEvent, specVersion string, content map[string]interface{}) (cdevents.CDEvent, error) {
+	data, err := json.Marshal(cdEvent)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	subject, _ := document["subject"].(map[string]interface{})
+	existing, _ := subject["content"].(map[string]interface{})
+	if existing == nil {
+		existing = map[string]interface{}{}
+	}
+	for key, value := range content {
+		existing[key] = value
+	}
+	subject["content"] = existing
+
+	if data, err = json.Marshal(document); err != nil {
+		return nil, err
+	}
+
+	// A new event is read into, as the SDK reads events into shared instances.
+	contentEvent, err := newEvent(cdEvent.GetType().UnversionedString(), specVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, contentEvent); err != nil {
+		return nil, fmt.Errorf("invalid subject content: %w", err)
+	}
+	return contentEvent, nil
+}
+
+// equalValues compares values from the payload and the mapping file by their
+// string representation, as numbers are decoded differently from each.
+func equalValues(value interface{}, expected interface{}) bool {
+	return fmt.Sprint(value) == fmt.Sprint(expected)
+}
+
+// expression is a value of a mapping: a JSONPath into the payload when it
+// starts with "$", a Go template executed on the payload when it contains
+// "{{" or else a literal string.
+type expression struct {
+	literal  string
+	path     []interface{}
+	template *template.Template
+}
+
+func compileExpression(value string) (*expression, error) {
+	switch {
+	case strings.HasPrefix(value, "$"):
+		return compileJSONPath(value)
+	case strings.Contains(value, "{{"):
+		tmpl, err := template.New("").Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %q: %w", value, err)
+		}
+		return &expression{template: tmpl}, nil
+	default:
+		return &expression{literal: value}, nil
+	}
+}
+
+// compileJSONPath compiles paths of field names and array indexes such as
+// "$.pull_request.labels[0].name" or "$['pull_request']".
+func compileJSONPath(value string) (*expression, error) {
+	rest, found := strings.CutPrefix(value, "$")
+	if !found {
+		return nil, fmt.Errorf("JSONPath %q must start with $", value)
+	}
+
+	path := []interface{}{}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated field name in JSONPath %q", value)
+			}
+			path = append(path, rest[2:end])
+			rest = rest[end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated index in JSONPath %q", value)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in JSONPath %q", value)
+			}
+			path = append(path, index)
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty field name in JSONPath %q", value)
+			}
+			path = append(path, rest[1:end+1])
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q", value)
+		}
+	}
+
+	return &expression{path: path}, nil
+}
+
+// evaluate returns the value of the expression on the payload, which is nil
+// when a JSONPath does not exist in the payload.
+func (e *expression) evaluate(payload interface{}) (interface{}, error) {
+	switch {
+	case e.template != nil:
+		var b strings.Builder
+		if err := e.template.Execute(&b, payload); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMissingRequiredFields, err)
+		}
+		return b.String(), nil
+	case e.path != nil:
+		value := payload
+		for _, segment := range e.path {
+			switch s := segment.(type) {
+			case string:
+				fields, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, nil
+				}
+				value = fields[s]
+			case int:
+				elements, ok := value.([]interface{})
+				if !ok || s < 0 || s >= len(elements) {
+					return nil, nil
+				}
+				value = elements[s]
+			}
+		}
+		return value, nil
+	default:
+		return e.literal, nil
+	}
+}
+
+func evaluateString(e *expression, payload interface{}) (string, error) {
+	if e == nil {
+		return "", nil
+	}
+	value, err := e.evaluate(payload)
+	if err != nil || value == nil {
+		return "", err
+	}
+	return fmt.Sprint(value), nil
+}
+
+// compileValue compiles the strings of a value from a mapping file, which may
+// be nested in objects and arrays, into expressions.
+func compileValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return compileExpression(v)
+	case map[string]interface{}:
+		compiled := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			c, err := compileValue(child)
+			if err != nil {
+				return nil, err
+			}
+			compiled[key] = c
+		}
+		return compiled, nil
+	case []interface{}:
+		compiled := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			c, err := compileValue(child)
+			if err != nil {
+				return nil, err
+			}
+			compiled = append(compiled, c)
+		}
+		return compiled, nil
+	default:
+		return v, nil
+	}
+}
+
+// evaluateValue evaluates the expressions of a compiled value on the payload.
+func evaluateValue(value interface{}, payload interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case *expression:
+		return v.evaluate(payload)
+	case map[string]interface{}:
+		evaluated := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			e, err := evaluateValue(child, payload)
+			if err != nil {
+				return nil, err
+			}
+			evaluated[key] = e
+		}
+		return evaluated, nil
+	case []interface{}:
+		evaluated := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			e, err := evaluateValue(child, payload)
+			if err != nil {
+				return nil, err
+			}
+			evaluated = append(evaluated, e)
+		}
+		return evaluated, nil
+	default:
+		return v, nil
+	}
+}
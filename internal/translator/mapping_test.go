@@ -0,0 +1,242 @@
+package translator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMappingFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "mappings.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644), "mapping file should be written")
+	return path
+}
+
+func TestLoadMappingFiles(t *testing.T) {
+
+	for _, tc := range []struct {
+		title            string
+		content          string
+		expectedSubjects []string
+		expectedError    bool
+	}{
+		{
+			title: "loads mappings by subject",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.deployed
+    source: git.example.com
+    subjectId: $.name
+  - subject: gitea.deployment
+    type: service.removed
+    source: git.example.com
+    subjectId: $.name
+  - subject: github.deployment_status
+    type: service.deployed
+    specVersion: 0.3.0
+    source: github.com
+    subjectId: "{{ .name }}"
+`,
+			expectedSubjects: []string{"gitea.deployment", "github.deployment_status"},
+		},
+		{
+			title:            "loads mappings from JSON",
+			content:          `{"mappings": [{"subject": "gitea.deployment", "type": "service.deployed", "source": "git.example.com", "subjectId": "$.name"}]}`,
+			expectedSubjects: []string{"gitea.deployment"},
+		},
+		{
+			title: "error on unknown event type",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.exploded
+    source: git.example.com
+    subjectId: $.name
+`,
+			expectedError: true,
+		},
+		{
+			title: "error on unsupported spec version",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.deployed
+    specVersion: 0.2.0
+    source: git.example.com
+    subjectId: $.name
+`,
+			expectedError: true,
+		},
+		{
+			title: "error on missing subject id",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.deployed
+    source: git.example.com
+`,
+			expectedError: true,
+		},
+		{
+			title: "error on invalid JSONPath",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.deployed
+    source: git.example.com
+    subjectId: $.items[first]
+`,
+			expectedError: true,
+		},
+		{
+			title: "error on invalid template",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.deployed
+    source: "{{ .repository"
+    subjectId: $.name
+`,
+			expectedError: true,
+		},
+		{
+			title: "error on invalid regular expression",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    match:
+      - path: $.ref
+        matches: "refs/(heads"
+    type: service.deployed
+    source: git.example.com
+    subjectId: $.name
+`,
+			expectedError: true,
+		},
+		{
+			title: "error on unknown field",
+			content: `
+mappings:
+  - subject: gitea.deployment
+    type: service.deployed
+    source: git.example.com
+    subjectId: $.name
+    subjectid: $.id
+`,
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			translators, err := LoadMappingFiles([]string{writeMappingFile(t, tc.content)})
+			if tc.expectedError {
+				assert.Error(t, err, "error should be returned")
+				return
+			}
+			require.NoError(t, err, "no error should be returned")
+
+			var subjects []string
+			for subject := range translators {
+				subjects = append(subjects, subject)
+			}
+			assert.ElementsMatch(t, tc.expectedSubjects, subjects)
+		})
+	}
+}
+
+func TestMappingTranslate(t *testing.T) {
+
+	translators, err := LoadMappingFiles([]string{filepath.Join("..", "..", "examples", "mappings", "github_deployment_status.yaml")})
+	require.NoError(t, err, "example mapping file should be loaded")
+	translator, exists := translators["github.deployment_status"]
+	require.True(t, exists, "translator should be loaded for subject")
+
+	payload, err := os.ReadFile(filepath.Join("..", "..", "examples", "webhooks", "github", "deployment_status_success.json"))
+	require.NoError(t, err, "example payload should be read")
+
+	cdEvents, err := translator.Translate(Request{Payload: payload, Subject: "webhooks.github.deployment_status"})
+	require.NoError(t, err, "no error should be returned when translating event")
+	cdEvent := singleEvent(t, cdEvents)
+	require.NotNil(t, cdEvent, "CD event must not be nil")
+
+	assert.Equal(t, cdevents.ServiceDeployedEventTypeV0_2_0, cdEvent.GetType(), "Event must have expected type")
+	assert.Equal(t, "0.4.1", cdEvent.GetVersion(), "Event must have spec version of mapping")
+	assert.Equal(t, "https://github.com/yoloco/project1", cdEvent.GetSource(), "Event Source must be evaluated template")
+	assert.Equal(t, "yoloco/project1/production", cdEvent.GetSubjectId(), "Subject ID must be evaluated template")
+	assert.Equal(t, "https://github.com/yoloco/project1", cdEvent.GetSubjectSource(), "Subject Source must be evaluated JSONPath")
+	assert.Equal(t, time.Date(2025, 2, 12, 9, 58, 0, 0, time.UTC), cdEvent.GetTimestamp().UTC(), "Event must be dated by payload")
+
+	content, ok := cdEvent.GetSubjectContent().(cdevents.ServiceDeployedSubjectContentV0_2_0)
+	require.True(t, ok, "failed to cast Subject Content")
+	require.NotNil(t, content.Environment, "Content environment must not be nil")
+	assert.Equal(t, "production", content.Environment.Id, "Content environment must be evaluated JSONPath")
+	assert.Equal(t, "pkg:github/yoloco/project1@9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2", content.ArtifactId, "Content artifact id must be evaluated template")
+
+	customData, err := cdEvent.GetCustomDataRaw()
+	require.NoError(t, err, "custom data should be marshalled")
+	assert.JSONEq(t, string(payload), string(customData), "custom data must be the payload")
+
+	t.Run("ignored when no mapping matches", func(t *testing.T) {
+		cdEvents, err := translator.Translate(Request{Payload: []byte(`{"deployment_status": {"state": "pending"}, "deployment": {"environment": "production"}}`)})
+		assert.Empty(t, cdEvents, "no events should be returned")
+		assert.ErrorIs(t, err, ErrIgnored)
+		assert.Equal(t, ReasonNoMatch, IgnoredReason(err))
+	})
+
+	t.Run("error when template refers to missing field", func(t *testing.T) {
+		_, err := translator.Translate(Request{Payload: []byte(`{"deployment_status": {"state": "success"}, "deployment": {"environment": "production"}}`)})
+		assert.ErrorIs(t, err, ErrMissingRequiredFields)
+	})
+}
+
+func TestMappingPredicates(t *testing.T) {
+
+	payload := map[string]interface{}{
+		"action": "published",
+		"number": 42,
+		"release": map[string]interface{}{
+			"draft":    false,
+			"tag_name": "v1.2.0",
+			"assets":   []interface{}{map[string]interface{}{"name": "app.tar.gz"}},
+		},
+	}
+
+	exists := true
+	missing := false
+
+	for _, tc := range []struct {
+		title         string
+		predicate     predicate
+		expectedMatch bool
+	}{
+		{"equals string", predicate{Path: "$.action", Equals: "published"}, true},
+		{"equals other string", predicate{Path: "$.action", Equals: "created"}, false},
+		{"equals bool", predicate{Path: "$.release.draft", Equals: false}, true},
+		{"equals number", predicate{Path: "$.number", Equals: 42}, true},
+		{"in values", predicate{Path: "$.action", In: []interface{}{"created", "published"}}, true},
+		{"not in values", predicate{Path: "$.action", In: []interface{}{"created", "deleted"}}, false},
+		{"exists", predicate{Path: "$.release.assets[0].name", Exists: &exists}, true},
+		{"exists when missing", predicate{Path: "$.release.assets[1].name", Exists: &exists}, false},
+		{"does not exist", predicate{Path: "$['release']['body']", Exists: &missing}, true},
+		{"matches regular expression", predicate{Path: "$.release.tag_name", Matches: `^v\d+\.\d+\.0$`}, true},
+		{"does not match regular expression", predicate{Path: "$.release.tag_name", Matches: `-rc\d+$`}, false},
+		{"does not match when missing", predicate{Path: "$.release.name", Matches: `.*`}, false},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			rule, err := compileMapping(mapping{
+				Subject:   "gitea.release",
+				Match:     []predicate{tc.predicate},
+				Type:      "artifact.published",
+				Source:    "git.example.com",
+				SubjectId: "$.release.tag_name",
+			})
+			require.NoError(t, err, "mapping should be compiled")
+			assert.Equal(t, tc.expectedMatch, rule.matches(payload))
+		})
+	}
+}
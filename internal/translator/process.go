@@ -0,0 +1,275 @@
+package translator
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+)
+
+var (
+	ErrProcessTimeout error = errors.New("Translator process did not respond in time")
+	ErrProcessExited  error = errors.New("Translator process exited")
+	ErrProcessReply   error = errors.New("Invalid reply from translator process")
+)
+
+// maxProcessLineSize is the size of the largest response line read from a
+// translator process.
+const maxProcessLineSize = 16 * 1024 * 1024
+
+// processRequest is written as a line of JSON to translator processes for each
+// webhook to translate, with an id that the reply must have.
+type processRequest struct {
+	Id      uint64          `json:"id"`
+	Subject string          `json:"subject"`
+	Headers http.Header     `json:"headers"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// processResponse is read as a line of JSON from translator processes in reply
+// to each request. It has the events that the webhook is translated into, a
+// reason for ignoring the webhook or an error.
+type processResponse struct {
+	Id      uint64            `json:"id"`
+	Events  []json.RawMessage `json:"events"`
+	Ignored string            `json:"ignored"`
+	Error   string            `json:"error"`
+}
+
+type process struct {
+	logger  *slog.Logger
+	command []string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	lastId  uint64
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	replies chan []byte
+}
+
+// NewProcess creates a translator that hands webhooks to a long-lived external
+// process, started with the command on first use and restarted whenever it has
+// exited. Webhooks are written to its stdin and the translated events read from
+// its stdout as newline-delimited JSON, one webhook at a time. A process that
+// does not read the request and reply within the timeout, or replies with
+// anything but a response to the current request, is killed.
+func NewProcess(logger *slog.Logger, command []string, timeout time.Duration) *process {
+	return &process{
+		logger:  logger,
+		command: command,
+		timeout: timeout,
+	}
+}
+
+func (p *process) Translate(req Request) ([]cdevents.CDEvent, error) {
+
+	response, err := p.call(processRequest{
+		Subject: req.Subject,
+		Headers: req.Headers,
+		Payload: req.Payload,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case response.Error != "":
+		return nil, fmt.Errorf("translator process failed: %s", response.Error)
+	case response.Ignored != "":
+		// The reason is part of the message only, since it is up to the process
+		// and would be unbounded as a metric label.
+		return nil, Ignore(ReasonProcess, "ignored by translator process: %s", response.Ignored)
+	}
+
+	cdEvents := make([]cdevents.CDEvent, 0, len(response.Events))
+	for _, data := range response.Events {
+		cdEvent, err := ParseEvent(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event from translator process: %w", err)
+		}
+		cdEvents = append(cdEvents, cdEvent)
+	}
+
+	return cdEvents, nil
+}
+
+// call writes the request to the process and returns its reply. A process
+// that has exited since the last call is restarted before the request is
+// written again. The process is stopped if the reply is not a response to the
+// request, so that the next request is not answered by a stale reply.
+func (p *process) call(req processRequest) (*processResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastId++
+	req.Id = p.lastId
+
+	request, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	request = append(request, '\n')
+
+	// The timeout covers writing the request too, as a process that stops
+	// reading its stdin blocks writes larger than the pipe buffer.
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	for attempt := 0; ; attempt++ {
+		if p.cmd == nil {
+			if err := p.start(); err != nil {
+				return nil, err
+			}
+		}
+
+		err := p.write(request, timer.C)
+		if err == nil {
+			break
+		}
+		if errors.Is(err, ErrProcessTimeout) || attempt > 0 {
+			return nil, err
+		}
+	}
+
+	select {
+	case reply, ok := <-p.replies:
+		if !ok {
+			p.stop()
+			return nil, ErrProcessExited
+		}
+		var response processResponse
+		if err := json.Unmarshal(reply, &response); err != nil {
+			p.stop()
+			return nil, fmt.Errorf("%w: %w", ErrProcessReply, err)
+		}
+		if response.Id != req.Id {
+			p.stop()
+			return nil, fmt.Errorf("%w: reply to request %d instead of %d", ErrProcessReply, response.Id, req.Id)
+		}
+		return &response, nil
+	case <-timer.C:
+		p.stop()
+		return nil, ErrProcessTimeout
+	}
+}
+
+// write writes the request to the stdin of the process, stopping the process
+// if the write fails or does not complete before the timeout.
+func (p *process) write(request []byte, timeout <-chan time.Time) error {
+	stdin := p.stdin
+	written := make(chan error, 1)
+	go func() {
+		_, err := stdin.Write(request)
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			p.stop()
+			return fmt.Errorf("%w: %w", ErrProcessExited, err)
+		}
+		return nil
+	case <-timeout:
+		// Stopping the process closes its stdin, which ends the write.
+		p.stop()
+		return ErrProcessTimeout
+	}
+}
+
+func (p *process) start() error {
+	cmd := exec.Command(p.command[0], p.command[1:]...)
+	cmd.Stderr = &processLogWriter{logger: p.logger, command: p.command[0]}
+	cmd.WaitDelay = time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start translator process: %w", err)
+	}
+
+	p.logger.Info("Started translator process", "command", p.command[0], "pid", cmd.Process.Pid)
+
+	replies := make(chan []byte)
+	go func() {
+		defer close(replies)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxProcessLineSize)
+		for scanner.Scan() {
+			replies <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+
+	p.cmd, p.stdin, p.replies = cmd, stdin, replies
+	return nil
+}
+
+// stop kills the process and waits for it to exit.
+func (p *process) stop() {
+	if p.cmd == nil {
+		return
+	}
+
+	p.cmd.Process.Kill()
+	err := p.cmd.Wait()
+	// Wait closes stdout, after which there are no more replies to discard.
+	for range p.replies {
+	}
+	p.logger.Warn("Translator process exited", "command", p.command[0], "error", err)
+
+	p.cmd, p.stdin, p.replies = nil, nil, nil
+}
+
+// Close stops the process.
+func (p *process) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stop()
+	return nil
+}
+
+// processLogWriter logs what translator processes write to stderr.
+type processLogWriter struct {
+	logger  *slog.Logger
+	command string
+}
+
+func (w *processLogWriter) Write(data []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		w.logger.Warn("Translator process: "+line, "command", w.command)
+	}
+	return len(data), nil
+}
+
+// ParseProcessTranslators parses entries of a translator name and the command
+// of its translator process separated by an equals sign, with the arguments of
+// the command separated by spaces, e.g. "gitea.deployment=/opt/translate.py -v".
+func ParseProcessTranslators(entries []string) (map[string][]string, error) {
+	commands := map[string][]string{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		name, command, found := strings.Cut(entry, "=")
+		if !found || name == "" || len(strings.Fields(command)) == 0 {
+			return nil, fmt.Errorf("missing translator or command in: %q", entry)
+		}
+		commands[name] = strings.Fields(command)
+	}
+	return commands, nil
+}
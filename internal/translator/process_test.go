@@ -0,0 +1,197 @@
+package translator
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcess(t *testing.T) {
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("translator process tests require sh")
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedEvent.SetSource("git.example.com")
+	changeMergedEvent.SetSubjectId("9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2")
+	changeMergedEvent.SetSubjectRepository(&cdevents.Reference{Id: "yoloco/project1"})
+	changeMergedJson, err := cdevents.AsJsonString(changeMergedEvent)
+	require.NoError(t, err, "unable to render CDEvent for tests")
+
+	branchCreatedEvent, err := cdeventsv04.NewBranchCreatedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	branchCreatedEvent.SetSource("git.example.com")
+	branchCreatedEvent.SetSubjectId("foo")
+	branchCreatedJson, err := cdevents.AsJsonString(branchCreatedEvent)
+	require.NoError(t, err, "unable to render CDEvent for tests")
+
+	// Replies with the id of the request according to the action of the
+	// payload, and with an extra event when the delivery header has been
+	// forwarded.
+	script := `
+while read -r line; do
+  id=$(echo "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+  case "$line" in
+    *'"action":"crash"'*) exit 1 ;;
+    *'"action":"slow"'*) sleep 5 ;;
+    *'"action":"ignore"'*) echo '{"id": '"$id"', "ignored": "not_interesting"}' ;;
+    *'"action":"fail"'*) echo '{"id": '"$id"', "error": "cannot translate"}' ;;
+    *'"action":"invalid"'*) echo '{"id": '"$id"', "events": [{"context": {"version": "0.4.1", "type": "dev.cdevents.change.merged.0.2.0"}}]}' ;;
+    *'"action":"garbage"'*) echo 'not json' ;;
+    *'"action":"other_id"'*) echo '{"id": 0, "events": [` + changeMergedJson + `]}' ;;
+    *'"X-Gitea-Delivery":["f6266f16"]'*) echo '{"id": '"$id"', "events": [` + changeMergedJson + `, ` + branchCreatedJson + `]}' ;;
+    *) echo '{"id": '"$id"', "events": [` + changeMergedJson + `]}' ;;
+  esac
+done
+`
+	scriptPath := filepath.Join(t.TempDir(), "translate.sh")
+	require.NoError(t, os.WriteFile(scriptPath, []byte(script), 0755), "script should be written")
+
+	translator := NewProcess(logger, []string{"sh", scriptPath}, 500*time.Millisecond)
+	defer translator.Close()
+
+	for _, tc := range []struct {
+		title              string
+		payload            string
+		headers            http.Header
+		expectedEventTypes []cdevents.CDEventType
+		expectedError      error
+		expectedReason     string
+	}{
+		{
+			title:              "returns event from process",
+			payload:            `{"action": "merged"}`,
+			expectedEventTypes: []cdevents.CDEventType{cdevents.ChangeMergedEventTypeV0_2_0},
+		},
+		{
+			title:              "returns events from process given headers",
+			payload:            `{"action": "merged"}`,
+			headers:            http.Header{"X-Gitea-Delivery": []string{"f6266f16"}},
+			expectedEventTypes: []cdevents.CDEventType{cdevents.ChangeMergedEventTypeV0_2_0, cdevents.BranchCreatedEventTypeV0_2_0},
+		},
+		{
+			title:          "ignored when process ignores webhook",
+			payload:        `{"action": "ignore"}`,
+			expectedError:  ErrIgnored,
+			expectedReason: ReasonProcess,
+		},
+		{
+			title:   "error when process fails to translate",
+			payload: `{"action": "fail"}`,
+		},
+		{
+			title:   "error when process returns invalid event",
+			payload: `{"action": "invalid"}`,
+		},
+		{
+			title:         "error when process exits",
+			payload:       `{"action": "crash"}`,
+			expectedError: ErrProcessExited,
+		},
+		{
+			title:              "restarts process after it exited",
+			payload:            `{"action": "merged"}`,
+			expectedEventTypes: []cdevents.CDEventType{cdevents.ChangeMergedEventTypeV0_2_0},
+		},
+		{
+			title:         "error when process replies with invalid JSON",
+			payload:       `{"action": "garbage"}`,
+			expectedError: ErrProcessReply,
+		},
+		{
+			title:              "restarts process after invalid reply",
+			payload:            `{"action": "merged"}`,
+			expectedEventTypes: []cdevents.CDEventType{cdevents.ChangeMergedEventTypeV0_2_0},
+		},
+		{
+			title:         "error when process replies to other request",
+			payload:       `{"action": "other_id"}`,
+			expectedError: ErrProcessReply,
+		},
+		{
+			title:              "restarts process after reply to other request",
+			payload:            `{"action": "merged"}`,
+			expectedEventTypes: []cdevents.CDEventType{cdevents.ChangeMergedEventTypeV0_2_0},
+		},
+		{
+			title:         "error when process does not reply in time",
+			payload:       `{"action": "slow"}`,
+			expectedError: ErrProcessTimeout,
+		},
+		{
+			title:              "restarts process after it timed out",
+			payload:            `{"action": "merged"}`,
+			expectedEventTypes: []cdevents.CDEventType{cdevents.ChangeMergedEventTypeV0_2_0},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvents, err := translator.Translate(Request{Payload: []byte(tc.payload), Headers: tc.headers, Subject: "webhooks.gitea.custom"})
+
+			if tc.expectedEventTypes == nil {
+				require.Error(t, err, "error should be returned")
+				if tc.expectedError != nil {
+					assert.ErrorIs(t, err, tc.expectedError)
+				}
+				assert.Equal(t, tc.expectedReason, IgnoredReason(err), "reason for ignoring webhook")
+				return
+			}
+
+			require.NoError(t, err, "no error should be returned when translating event")
+			require.Len(t, cdEvents, len(tc.expectedEventTypes), "unexpected number of events")
+			for i, cdEvent := range cdEvents {
+				assert.Equal(t, tc.expectedEventTypes[i], cdEvent.GetType(), "Event must have expected type")
+				assert.Equal(t, "git.example.com", cdEvent.GetSource(), "Event Source must be that of the process")
+			}
+		})
+	}
+}
+
+func TestProcessNotReadingStdin(t *testing.T) {
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("translator process tests require sh")
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	translator := NewProcess(logger, []string{"sh", "-c", "exec sleep 60"}, 200*time.Millisecond)
+	defer translator.Close()
+
+	// Larger than the pipe buffer, so that writing it blocks.
+	payload := `{"data": "` + strings.Repeat("x", 1024*1024) + `"}`
+
+	start := time.Now()
+	_, err := translator.Translate(Request{Payload: []byte(payload), Subject: "webhooks.gitea.custom"})
+	assert.ErrorIs(t, err, ErrProcessTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second, "timeout must be enforced while writing the request")
+}
+
+func TestParseProcessTranslators(t *testing.T) {
+
+	commands, err := ParseProcessTranslators([]string{"gitea.deployment=/opt/translate.py -v", " github.check_run=translate-checks "})
+	require.NoError(t, err, "no error should be returned")
+	assert.Equal(t, map[string][]string{
+		"gitea.deployment": {"/opt/translate.py", "-v"},
+		"github.check_run": {"translate-checks"},
+	}, commands)
+
+	for _, entry := range []string{"gitea.deployment", "=translate", "gitea.deployment= "} {
+		_, err := ParseProcessTranslators([]string{entry})
+		assert.Error(t, err, "error should be returned for %q", entry)
+	}
+}
@@ -0,0 +1,85 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv03 "github.com/cdevents/sdk-go/pkg/api/v03"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+)
+
+// SpecVersions holds the spec version that the events of each translator are
+// published in, with the spec version under "*" applying to translators
+// without one of their own. An empty spec version publishes events in the
+// version they are translated into.
+type SpecVersions map[string]string
+
+// For returns the spec version of the translator.
+func (s SpecVersions) For(name string) string {
+	if specVersion, exists := s[name]; exists {
+		return specVersion
+	}
+	return s["*"]
+}
+
+// ParseSpecVersions parses entries of a translator name, or "*" for all
+// translators, and a spec version separated by an equals sign, e.g.
+// "gitea.push=0.3.0". The default spec version applies to translators without
+// an entry unless there is one for "*".
+func ParseSpecVersions(entries []string, defaultVersion string) (SpecVersions, error) {
+	if err := checkSpecVersion(defaultVersion); err != nil {
+		return nil, err
+	}
+
+	specVersions := SpecVersions{"*": defaultVersion}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		name, specVersion, found := strings.Cut(entry, "=")
+		if !found || name == "" || specVersion == "" {
+			return nil, fmt.Errorf("missing translator or spec version in: %q", entry)
+		}
+		if err := checkSpecVersion(specVersion); err != nil {
+			return nil, fmt.Errorf("%w in: %q", err, entry)
+		}
+		specVersions[name] = specVersion
+	}
+	return specVersions, nil
+}
+
+func checkSpecVersion(specVersion string) error {
+	switch specVersion {
+	case "", cdeventsv03.SpecVersion, cdeventsv04.SpecVersion:
+		return nil
+	}
+	return fmt.Errorf("unsupported spec version %q, expected %s or %s", specVersion, cdeventsv03.SpecVersion, cdeventsv04.SpecVersion)
+}
+
+// WithSpecVersion converts the events translated by the translator to the spec
+// version, or leaves them as they are when it is empty.
+func WithSpecVersion(webhook Webhook, specVersion string) Webhook {
+	if specVersion == "" {
+		return webhook
+	}
+	return &specVersionWebhook{webhook: webhook, specVersion: specVersion}
+}
+
+type specVersionWebhook struct {
+	webhook     Webhook
+	specVersion string
+}
+
+func (s *specVersionWebhook) Translate(req Request) ([]cdevents.CDEvent, error) {
+	cdEvents, err := s.webhook.Translate(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, cdEvent := range cdEvents {
+		if cdEvents[i], err = ConvertEvent(cdEvent, s.specVersion); err != nil {
+			return nil, fmt.Errorf("failed to convert event of type %s to spec version %s: %w", cdEvent.GetType(), s.specVersion, err)
+		}
+	}
+
+	return cdEvents, nil
+}
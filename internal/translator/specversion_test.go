@@ -0,0 +1,85 @@
+package translator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpecVersions(t *testing.T) {
+
+	for _, tc := range []struct {
+		title                string
+		entries              []string
+		defaultVersion       string
+		expectedSpecVersions SpecVersions
+		expectedError        bool
+	}{
+		{
+			title:                "keeps spec versions by default",
+			expectedSpecVersions: SpecVersions{"*": ""},
+		},
+		{
+			title:                "parses spec versions for all and single translators",
+			entries:              []string{"gitea.push=0.3.0", "*=0.4.1"},
+			defaultVersion:       "0.3.0",
+			expectedSpecVersions: SpecVersions{"*": "0.4.1", "gitea.push": "0.3.0"},
+		},
+		{
+			title:          "error on unsupported default spec version",
+			defaultVersion: "0.4",
+			expectedError:  true,
+		},
+		{
+			title:         "error on missing spec version",
+			entries:       []string{"gitea.push="},
+			expectedError: true,
+		},
+		{
+			title:         "error on unsupported spec version",
+			entries:       []string{"gitea.push=0.2.0"},
+			expectedError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			specVersions, err := ParseSpecVersions(tc.entries, tc.defaultVersion)
+			if tc.expectedError {
+				assert.Error(t, err, "error should be returned")
+				return
+			}
+			require.NoError(t, err, "no error should be returned")
+			assert.Equal(t, tc.expectedSpecVersions, specVersions)
+		})
+	}
+}
+
+func TestSpecVersionsFor(t *testing.T) {
+
+	specVersions := SpecVersions{
+		"*":          "0.4.1",
+		"gitea.push": "0.3.0",
+	}
+
+	assert.Equal(t, "0.3.0", specVersions.For("gitea.push"), "spec version of translator")
+	assert.Equal(t, "0.4.1", specVersions.For("gitea.create"), "spec version for all translators")
+}
+
+func TestWithSpecVersion(t *testing.T) {
+
+	payload, err := os.ReadFile(filepath.Join("..", "..", "examples", "webhooks", "gitea", "pull_request_closed.json"))
+	require.NoError(t, err, "example payload should be read")
+
+	webhook := &GiteaPullRequest{}
+	assert.Same(t, webhook, WithSpecVersion(webhook, ""), "translator must be kept without spec version")
+
+	cdEvents, err := WithSpecVersion(webhook, "0.3.0").Translate(Request{Payload: payload})
+	require.NoError(t, err, "no error should be returned when translating event")
+	cdEvent := singleEvent(t, cdEvents)
+
+	assert.Equal(t, "0.3.0", cdEvent.GetVersion(), "Event must have converted spec version")
+	assert.Equal(t, cdevents.ChangeMergedEventTypeV0_1_2, cdEvent.GetType(), "Event must have type of converted spec version")
+}
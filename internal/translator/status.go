@@ -0,0 +1,67 @@
+package translator
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Kinds of CDEvents that commit statuses can be reported as.
+const (
+	StatusKindPipeline  = "pipeline"
+	StatusKindTestSuite = "testsuite"
+	StatusKindIgnore    = "ignore"
+)
+
+// StatusRule reports commit statuses with a context matching the glob
+// pattern, as matched by path.Match, as the given kind of CDEvent.
+type StatusRule struct {
+	Pattern string
+	Kind    string
+}
+
+// StatusPolicy decides what kind of CDEvent commit statuses are reported as.
+// The first rule matching the status context applies, statuses not matching
+// any rule are reported as pipeline runs.
+type StatusPolicy struct {
+	Rules []StatusRule
+}
+
+// Kind returns the kind of CDEvent that statuses with the context should be
+// reported as.
+func (s StatusPolicy) Kind(context string) string {
+	for _, rule := range s.Rules {
+		if matched, _ := path.Match(rule.Pattern, context); matched {
+			return rule.Kind
+		}
+	}
+	return StatusKindPipeline
+}
+
+// ParseStatusRules parses entries of a glob pattern and a kind separated by
+// an equals sign, e.g. "ci/test-*=testsuite".
+func ParseStatusRules(entries []string) ([]StatusRule, error) {
+	var rules []StatusRule
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		i := strings.LastIndex(entry, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("missing pattern or kind in: %q", entry)
+		}
+
+		rule := StatusRule{Pattern: entry[:i], Kind: entry[i+1:]}
+
+		switch rule.Kind {
+		case StatusKindPipeline, StatusKindTestSuite, StatusKindIgnore:
+		default:
+			return nil, fmt.Errorf("unknown status kind %q in: %q", rule.Kind, entry)
+		}
+
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid status context pattern %q: %w", rule.Pattern, err)
+		}
+
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,76 @@
+package translator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStatusRules(t *testing.T) {
+
+	for _, tc := range []struct {
+		title         string
+		entries       []string
+		expectedRules []StatusRule
+		expectError   bool
+	}{
+		{
+			title:         "no entries",
+			entries:       nil,
+			expectedRules: nil,
+		},
+		{
+			title:   "rules of all kinds",
+			entries: []string{"ci/test-*=testsuite", " ci/lint=ignore ", "*=pipeline"},
+			expectedRules: []StatusRule{
+				{Pattern: "ci/test-*", Kind: StatusKindTestSuite},
+				{Pattern: "ci/lint", Kind: StatusKindIgnore},
+				{Pattern: "*", Kind: StatusKindPipeline},
+			},
+		},
+		{
+			title:       "error on missing kind",
+			entries:     []string{"ci/test-*"},
+			expectError: true,
+		},
+		{
+			title:       "error on unknown kind",
+			entries:     []string{"ci/test-*=tests"},
+			expectError: true,
+		},
+		{
+			title:       "error on malformed pattern",
+			entries:     []string{"ci/[=testsuite"},
+			expectError: true,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			rules, err := ParseStatusRules(tc.entries)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedRules, rules)
+		})
+	}
+}
+
+func TestStatusPolicyKind(t *testing.T) {
+
+	policy := StatusPolicy{Rules: []StatusRule{
+		{Pattern: "ci/test-*", Kind: StatusKindTestSuite},
+		{Pattern: "ci/*", Kind: StatusKindIgnore},
+	}}
+
+	for context, expectedKind := range map[string]string{
+		"ci/test-unit": StatusKindTestSuite,
+		"ci/lint":      StatusKindIgnore,
+		"jenkins":      StatusKindPipeline,
+	} {
+		assert.Equal(t, expectedKind, policy.Kind(context), "kind of context %s", context)
+	}
+}
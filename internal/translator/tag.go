@@ -0,0 +1,70 @@
+package translator
+
+import (
+	"strings"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+)
+
+// Tags have no CDEvents of their own, so they are reported as custom events,
+// e.g. dev.cdeventsx.git-tag.created.0.1.0, with the tag name as subject id.
+var (
+	TagCreatedEventType = cdevents.CDEventType{Subject: "tag", Predicate: "created", Version: "0.1.0", Custom: "git"}
+	TagDeletedEventType = cdevents.CDEventType{Subject: "tag", Predicate: "deleted", Version: "0.1.0", Custom: "git"}
+)
+
+// TagSubjectContent is the subject content of the custom tag events.
+type TagSubjectContent struct {
+	Repository *cdevents.Reference `json:"repository,omitempty"`
+	Sha        string              `json:"sha,omitempty"`
+}
+
+// customEvent works around CustomTypeEvent of the SDK always returning the
+// unset event type from GetType, whatever type the event has been given.
+type customEvent struct {
+	*cdeventsv04.CustomTypeEvent
+}
+
+func (e customEvent) GetType() cdevents.CDEventType {
+	return e.Context.Type
+}
+
+func newTagEvent(eventType cdevents.CDEventType, repository string, tag string, sha string) (cdevents.CDEvent, error) {
+	customTypeEvent, err := cdeventsv04.NewCustomTypeEvent()
+	if err != nil {
+		return nil, err
+	}
+	customTypeEvent.SetEventType(eventType)
+	customTypeEvent.SetSubjectId(tag)
+	customTypeEvent.SetSubjectContent(TagSubjectContent{
+		Repository: &cdevents.Reference{Id: repository},
+		Sha:        sha,
+	})
+	return customEvent{customTypeEvent}, nil
+}
+
+// isCommitSha reports whether the value is the full object name of a commit,
+// as opposed to e.g. a branch name.
+func isCommitSha(value string) bool {
+	if len(value) != 40 && len(value) != 64 {
+		return false
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// isZeroSha reports whether the sha is the all zero object name that git
+// uses for the missing side of a created or deleted ref.
+func isZeroSha(sha string) bool {
+	for _, c := range sha {
+		if c != '0' {
+			return false
+		}
+	}
+	return sha != ""
+}
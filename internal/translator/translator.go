@@ -1,9 +1,302 @@
 package translator
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/structs"
 	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Reasons for ignoring webhooks.
+const (
+	ReasonNoCommits         = "no_commits"
+	ReasonPing              = "ping"
+	ReasonUnsupportedAction = "unsupported_action"
+	ReasonUnsupportedRef    = "unsupported_ref_type"
+	ReasonPolicy            = "policy"
+	ReasonAlreadyReported   = "already_reported"
+	ReasonNotFinal          = "not_final"
+	ReasonPullRequest       = "pull_request"
+	ReasonNoMatch           = "no_match"
+	ReasonDuplicate         = "duplicate"
+	ReasonProcess           = "process"
+)
+
+var (
+	ErrMissingRequiredFields error = errors.New("Event payload is missing required fields, cannot convert to a CD Event")
+	ErrUnsupportedPRAction   error = errors.New("Unsupported Pull Request action, cannot convert to a CD Event")
+	ErrUnsupportedRefType    error = errors.New("Unsupported ref type, cannot convert to a CD Event")
+	ErrUnsupportedAction     error = errors.New("Unsupported action, cannot convert to a CD Event")
+	// ErrIgnored is matched by all errors returned when an event is
+	// deliberately not converted to a CD Event.
+	ErrIgnored error = errors.New("Event ignored, will not convert to a CD Event")
+	// ErrTemporary is matched by all errors returned when an event cannot be
+	// converted to a CD Event for the time being and should be retried.
+	ErrTemporary error = errors.New("Event cannot be converted to a CD Event for now")
+	// ErrMergeNotReported is matched by the temporary errors of pushes to merge
+	// branches that wait for the pull request of the merge to be reported.
+	ErrMergeNotReported error = errors.New("Merge not yet reported by pull request")
 )
 
+// IgnoredError is returned when a webhook is deliberately not translated,
+// e.g. because there is nothing of interest in it, with one of the Reason
+// constants telling why.
+type IgnoredError struct {
+	Reason  string
+	Message string
+}
+
+func (e *IgnoredError) Error() string {
+	return e.Message
+}
+
+func (e *IgnoredError) Is(target error) bool {
+	return target == ErrIgnored
+}
+
+// Ignore returns an IgnoredError for the reason, described by the message.
+func Ignore(reason string, format string, args ...interface{}) error {
+	return &IgnoredError{Reason: reason, Message: fmt.Sprintf("%s: %s", ErrIgnored, fmt.Sprintf(format, args...))}
+}
+
+// ignoreKnown returns an IgnoredError for the reason if the value, e.g. the
+// action of a webhook, is one of the known values that there are no events for,
+// or the error for values that are not known at all.
+func ignoreKnown(reason string, value string, known []string, err error) error {
+	if slices.Contains(known, value) {
+		return Ignore(reason, "%q has no events", value)
+	}
+	return err
+}
+
+// IgnoredReason returns the reason that the error ignores the webhook for, or
+// the empty string unless it is an IgnoredError.
+func IgnoredReason(err error) string {
+	var ignoredErr *IgnoredError
+	if errors.As(err, &ignoredErr) {
+		return ignoredErr.Reason
+	}
+	return ""
+}
+
+// TemporaryError is returned when a webhook cannot be translated for the time
+// being, e.g. because the merge recorder is unavailable, so that it is retried
+// rather than handed to the invalid message handler.
+type TemporaryError struct {
+	Err error
+}
+
+func (e *TemporaryError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTemporary, e.Err)
+}
+
+func (e *TemporaryError) Is(target error) bool {
+	return target == ErrTemporary
+}
+
+func (e *TemporaryError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary returns a TemporaryError for the error.
+func Temporary(err error) error {
+	return &TemporaryError{Err: err}
+}
+
+// Ping translates the pings sent when a webhook is set up or tested, which
+// there is nothing to translate into.
+type Ping struct{}
+
+func (p *Ping) Translate(req Request) ([]cdevents.CDEvent, error) {
+	return nil, Ignore(ReasonPing, "webhook ping")
+}
+
+// Request is a webhook to translate, as delivered by JetStream.
+type Request struct {
+	// Payload is the body of the webhook request.
+	Payload []byte
+	// Headers are the headers of the webhook request forwarded by the webhook
+	// handler, such as X-Gitea-Delivery.
+	Headers http.Header
+	// Subject is the subject of the JetStream message, e.g. webhooks.gitea.push.
+	Subject string
+	// Metadata is the metadata of the JetStream message, if any.
+	Metadata *jetstream.MsgMetadata
+	// FinalDelivery is set when the message will not be redelivered, so that
+	// the webhook cannot be retried later.
+	FinalDelivery bool
+}
+
+// Webhook translates webhooks into CD Events. A webhook that there is nothing
+// to emit for is translated into no events and an IgnoredError, created with
+// Ignore, that tells why.
 type Webhook interface {
-	Translate(data []byte) (cdevents.CDEvent, error)
+	Translate(req Request) ([]cdevents.CDEvent, error)
+}
+
+// MergeRecorder keeps track of the merges reported by pull requests, so that
+// a merge notified by both a pull request and a push is only translated into
+// the ChangeMerged event of the pull request.
+type MergeRecorder interface {
+	// Record marks the merge of the commit in the repository as reported by a
+	// pull request.
+	Record(repository string, sha string) error
+	// Recorded reports whether the merge of the commit in the repository has
+	// been reported by a pull request.
+	Recorded(repository string, sha string) (bool, error)
+}
+
+// recordMerge marks the merge as reported by a pull request. Nothing is
+// recorded without a recorder or commit sha.
+func recordMerge(merges MergeRecorder, repository string, sha string) error {
+	if merges == nil || sha == "" {
+		return nil
+	}
+
+	if err := merges.Record(repository, sha); err != nil {
+		return Temporary(fmt.Errorf("failed to record merge of %s in %s: %w", sha, repository, err))
+	}
+
+	return nil
+}
+
+// checkMerge returns ErrIgnored if the merge pushed has been reported by a pull
+// request. As the webhook of the pull request may arrive after that of the push,
+// a merge not yet reported is retried until the wait has passed since the push
+// was received, or until its final delivery, after which the push is reported
+// itself as a direct push. Nothing is checked without a recorder.
+func checkMerge(merges MergeRecorder, repository string, sha string, req Request, wait time.Duration) error {
+	if merges == nil {
+		return nil
+	}
+
+	reported, err := merges.Recorded(repository, sha)
+	if err != nil {
+		return Temporary(fmt.Errorf("failed to look up merge of %s in %s: %w", sha, repository, err))
+	}
+
+	if reported {
+		return Ignore(ReasonAlreadyReported, "merge of %s in %s already reported by pull request", sha, repository)
+	}
+
+	var received time.Time
+	if req.Metadata != nil {
+		received = req.Metadata.Timestamp
+	}
+
+	if !req.FinalDelivery && time.Since(received) < wait {
+		return Temporary(fmt.Errorf("%w: merge of %s in %s", ErrMergeNotReported, sha, repository))
+	}
+
+	return nil
+}
+
+// timestampLayouts are the layouts of the timestamps found in webhook payloads,
+// of which GitLab uses its own in some places.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+}
+
+// setTimestampFromPayload dates the event by the first of the timestamps from
+// the webhook payload that can be parsed. The timestamp of the event is left
+// as is when none of them can.
+func setTimestampFromPayload(cdEvent cdevents.CDEvent, timestamps ...string) {
+	for _, timestamp := range timestamps {
+		for _, layout := range timestampLayouts {
+			if parsed, err := time.Parse(layout, timestamp); err == nil {
+				cdEvent.SetTimestamp(parsed)
+				return
+			}
+		}
+	}
+}
+
+// pullRequestTimestamps returns the timestamps of a pull request that best date
+// the action, in order of preference: when it was merged or closed for closed
+// pull requests and when it was created or last updated for the others.
+func pullRequestTimestamps(action string, createdAt string, updatedAt string, closedAt string, mergedAt string) []string {
+	switch action {
+	case "opened":
+		return []string{createdAt}
+	case "closed":
+		return []string{mergedAt, closedAt}
+	default:
+		return []string{updatedAt}
+	}
+}
+
+func addSourcesFromRepositoryUrl(webhookEvent interface{}, cdEvent cdevents.CDEvent) error {
+
+	var rawRepoUrl string
+	switch v := webhookEvent.(type) {
+	case structs.GiteaCreateEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaDeleteEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaPushEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaPullRequestEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaReleaseEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaIssueEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaRepositoryEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaStatusEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaWorkflowRunEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GiteaWorkflowJobEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GitHubCreateEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GitHubDeleteEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GitHubPushEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GitHubPullRequestEvent:
+		rawRepoUrl = v.Repository.HtmlUrl
+	case structs.GitLabPushEvent:
+		rawRepoUrl = v.Project.WebUrl
+	case structs.GitLabMergeRequestEvent:
+		rawRepoUrl = v.Project.WebUrl
+	default:
+		panic(fmt.Sprintf("failed to extract repository URL from webhook event with type: %T", webhookEvent))
+	}
+
+	if rawRepoUrl == "" {
+		return errors.New("Missing required field: repository URL")
+	}
+
+	return addSourcesFromUrl(rawRepoUrl, cdEvent)
+}
+
+// addSourcesFromUrl sets the host of the URL as event source and the host and
+// path as subject source.
+func addSourcesFromUrl(rawUrl string, cdEvent cdevents.CDEvent) error {
+
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	cdEvent.SetSource(parsedUrl.Host)
+
+	subjectSource, err := url.JoinPath(parsedUrl.Host, parsedUrl.Path)
+	if err != nil {
+		return err
+	}
+
+	cdEvent.SetSubjectSource(subjectSource)
+
+	return nil
 }
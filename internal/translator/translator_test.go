@@ -0,0 +1,131 @@
+package translator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	cdevents "github.com/cdevents/sdk-go/pkg/api"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockMergeRecorder struct {
+	mock.Mock
+}
+
+func (m *mockMergeRecorder) Record(repository string, sha string) error {
+	args := m.Called(repository, sha)
+	return args.Error(0)
+}
+
+func (m *mockMergeRecorder) Recorded(repository string, sha string) (bool, error) {
+	args := m.Called(repository, sha)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestIgnoredReason(t *testing.T) {
+
+	for _, tc := range []struct {
+		title          string
+		err            error
+		expectedReason string
+	}{
+		{
+			title:          "reason of ignored error",
+			err:            Ignore(ReasonNoCommits, "push to %s has no new commits", "refs/heads/main"),
+			expectedReason: ReasonNoCommits,
+		},
+		{
+			title:          "reason of wrapped ignored error",
+			err:            fmt.Errorf("translating push: %w", Ignore(ReasonPolicy, "ignored by policy")),
+			expectedReason: ReasonPolicy,
+		},
+		{
+			title:          "reason of known action without events",
+			err:            ignoreKnown(ReasonUnsupportedAction, "assigned", []string{"assigned"}, ErrUnsupportedAction),
+			expectedReason: ReasonUnsupportedAction,
+		},
+		{
+			title: "no reason of unknown action",
+			err:   ignoreKnown(ReasonUnsupportedAction, "unknown", []string{"assigned"}, ErrUnsupportedAction),
+		},
+		{
+			title: "no reason of other errors",
+			err:   ErrMissingRequiredFields,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			assert.Equal(t, tc.expectedReason != "", errors.Is(tc.err, ErrIgnored), "error should match ErrIgnored when ignored")
+			assert.Equal(t, tc.expectedReason, IgnoredReason(tc.err))
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	cdEvents, err := (&Ping{}).Translate(Request{Payload: []byte(`{"zen": "Keep it logically awesome."}`)})
+	assert.Empty(t, cdEvents, "no events should be returned")
+	assert.ErrorIs(t, err, ErrIgnored)
+	assert.Equal(t, ReasonPing, IgnoredReason(err))
+}
+
+func TestSetTimestampFromPayload(t *testing.T) {
+
+	translatedAt := time.Date(2025, 2, 12, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		title             string
+		timestamps        []string
+		expectedTimestamp time.Time
+	}{
+		{
+			title:             "parses RFC 3339 timestamp",
+			timestamps:        []string{"2025-02-12T10:00:00+01:00"},
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:             "parses GitLab timestamp with zone name",
+			timestamps:        []string{"2025-02-12 09:00:00 UTC"},
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:             "parses GitLab timestamp with zone offset",
+			timestamps:        []string{"2025-02-12 10:00:00 +0100"},
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:             "uses first timestamp that can be parsed",
+			timestamps:        []string{"", "yesterday", "2025-02-12T09:00:00Z", "2025-02-12T11:00:00Z"},
+			expectedTimestamp: time.Date(2025, 2, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			title:             "leaves timestamp unchanged when none can be parsed",
+			timestamps:        []string{"", "yesterday"},
+			expectedTimestamp: translatedAt,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			cdEvent, err := cdeventsv04.NewChangeMergedEvent()
+			require.NoError(t, err, "unable to create CDEvent for tests")
+			cdEvent.SetTimestamp(translatedAt)
+
+			setTimestampFromPayload(cdEvent, tc.timestamps...)
+
+			assert.True(t, tc.expectedTimestamp.Equal(cdEvent.GetTimestamp()), "expected timestamp %s, got %s", tc.expectedTimestamp, cdEvent.GetTimestamp())
+		})
+	}
+}
+
+// singleEvent returns the event translated by translators that translate
+// webhooks into at most one event, or nil if there is none.
+func singleEvent(t *testing.T, cdEvents []cdevents.CDEvent) cdevents.CDEvent {
+	t.Helper()
+	require.LessOrEqual(t, len(cdEvents), 1, "at most one event should be translated")
+	if len(cdEvents) == 0 {
+		return nil
+	}
+	return cdEvents[0]
+}
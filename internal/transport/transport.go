@@ -1,12 +1,14 @@
 package transport
 
 import (
+	"bytes"
 	"context"
 	"time"
 
 	cdevents "github.com/cdevents/sdk-go/pkg/api"
 	cejsm "github.com/cloudevents/sdk-go/protocol/nats_jetstream/v3"
-	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/cloudevents/sdk-go/v2/event"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
 )
@@ -15,50 +17,93 @@ type JetstreamPublisher interface {
 	Publish(ctx context.Context, subject string, data []byte, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
 }
 
+type JetstreamMsgPublisher interface {
+	PublishMsg(ctx context.Context, msg *nats.Msg, opts ...jetstream.PublishOpt) (*jetstream.PubAck, error)
+}
+
 type JetstreamMsg interface {
 	Data() []byte
+	Headers() nats.Header
 	Subject() string
 	Ack() error
+	Nak() error
+	NakWithDelay(delay time.Duration) error
+	InProgress() error
+	Term() error
 	Metadata() (*jetstream.MsgMetadata, error)
 }
 
-type CloudEventPublisher interface {
-	Publish(cdEvent cdevents.CDEvent) error
+type JetstreamStream interface {
+	GetMsg(ctx context.Context, seq uint64, opts ...jetstream.GetMsgOpt) (*jetstream.RawStreamMsg, error)
+	DeleteMsg(ctx context.Context, seq uint64) error
 }
 
-type cloudEventJetStreamPublisher struct {
-	nc *nats.Conn
+type JetstreamKeyValue interface {
+	Put(ctx context.Context, key string, value []byte) (uint64, error)
+	Get(ctx context.Context, key string) (jetstream.KeyValueEntry, error)
 }
 
-func NewCloudEventJetStreamPublisher(nc *nats.Conn) *cloudEventJetStreamPublisher {
-	return &cloudEventJetStreamPublisher{nc: nc}
+type CloudEventPublisher interface {
+	Publish(ctx context.Context, cdEvent cdevents.CDEvent, opts ...PublishOpt) (*jetstream.PubAck, error)
 }
 
-func (p *cloudEventJetStreamPublisher) Publish(cdEvent cdevents.CDEvent) error {
-	cloudEvent, err := cdevents.AsCloudEvent(cdEvent)
-	if err != nil {
-		return err
+// PublishOpt modifies the CloudEvent before it is published.
+type PublishOpt func(cloudEvent *event.Event)
+
+// WithIngestTime records the time at which the event, or the webhook it was
+// translated from, was received in the ingesttime extension attribute.
+func WithIngestTime(ingestTime time.Time) PublishOpt {
+	return func(cloudEvent *event.Event) {
+		if !ingestTime.IsZero() {
+			cloudEvent.SetExtension("ingesttime", ingestTime)
+		}
+	}
+}
+
+// WithDataSchema sets the dataschema attribute to the URI of the schema that
+// the custom data of the event adheres to.
+func WithDataSchema(uri string) PublishOpt {
+	return func(cloudEvent *event.Event) {
+		if uri != "" {
+			cloudEvent.SetDataSchema(uri)
+		}
 	}
+}
 
-	connOpt := cejsm.WithConnection(p.nc)
-	sendopt := cejsm.WithSendSubject(cloudEvent.Context.GetType())
+type cloudEventJetStreamPublisher struct {
+	js JetstreamMsgPublisher
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
-	defer cancel()
+// NewCloudEventJetStreamPublisher creates a publisher that is meant to be
+// created once and shared, as it reuses the given JetStream client for every
+// event instead of setting up a new CloudEvents protocol per publish.
+func NewCloudEventJetStreamPublisher(js JetstreamMsgPublisher) *cloudEventJetStreamPublisher {
+	return &cloudEventJetStreamPublisher{js: js}
+}
 
-	proto, err := cejsm.New(ctx, connOpt, sendopt)
+// Publish sends the CDEvent as a CloudEvent to the subject given by the event
+// type, encoded in the same way as the CloudEvents JetStream protocol does. The
+// time of the CloudEvent is the timestamp of the CDEvent.
+func (p *cloudEventJetStreamPublisher) Publish(ctx context.Context, cdEvent cdevents.CDEvent, opts ...PublishOpt) (*jetstream.PubAck, error) {
+	cloudEvent, err := cdevents.AsCloudEvent(cdEvent)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	client, err := cloudevents.NewClient(proto)
-	if err != nil {
-		return err
+	cloudEvent.SetTime(cdEvent.GetTimestamp())
+	for _, opt := range opts {
+		opt(cloudEvent)
 	}
 
-	if err := client.Send(ctx, *cloudEvent); err != nil {
-		return err
+	writer := new(bytes.Buffer)
+	header, err := cejsm.WriteMsg(ctx, binding.ToMessage(cloudEvent), writer)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return p.js.PublishMsg(ctx, &nats.Msg{
+		Subject: cloudEvent.Context.GetType(),
+		Data:    writer.Bytes(),
+		Header:  header,
+	})
 }
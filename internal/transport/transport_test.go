@@ -0,0 +1,55 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ansig/jetstream-cdevents-sink/internal/mocks"
+	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
+	cdeventsv04 "github.com/cdevents/sdk-go/pkg/api/v04"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudEventJetStreamPublisher(t *testing.T) {
+
+	changeMergedEvent, err := cdeventsv04.NewChangeMergedEvent()
+	require.NoError(t, err, "unable to create CDEvent for tests")
+	changeMergedEvent.SetSource("git.example.com")
+	changeMergedEvent.SetSubjectId("9d7b2d18bf7f315c666a4b3607f47bd452e7c8d2")
+	changeMergedEvent.SetTimestamp(time.Date(2025, 2, 12, 9, 59, 0, 0, time.UTC))
+
+	ingestTime := time.Date(2025, 2, 12, 10, 0, 0, 0, time.UTC)
+
+	mockJS := &mocks.JetstreamPublisher{}
+	mockJS.On("PublishMsg", mock.Anything).Return(&jetstream.PubAck{Stream: "cdevents", Sequence: 42}, nil)
+
+	publisher := transport.NewCloudEventJetStreamPublisher(mockJS)
+
+	for i := 0; i < 2; i++ {
+		pubAck, err := publisher.Publish(context.Background(), changeMergedEvent, transport.WithIngestTime(ingestTime), transport.WithDataSchema("https://adapter.example.com/schemas/customdata/gitea.push/v1.json"))
+		require.NoError(t, err, "no error should be returned when publishing")
+		assert.Equal(t, "cdevents", pubAck.Stream, "PubAck stream should be returned")
+		assert.Equal(t, uint64(42), pubAck.Sequence, "PubAck sequence should be returned")
+	}
+
+	mockJS.AssertNumberOfCalls(t, "PublishMsg", 2)
+
+	msg := mockJS.Calls[0].Arguments.Get(0).(*nats.Msg)
+	assert.Equal(t, changeMergedEvent.GetType().String(), msg.Subject, "Subject must be the CDEvent type")
+
+	assert.Equal(t, changeMergedEvent.GetId(), msg.Header.Get("ce-id"), "CloudEvent id must be the CDEvent id")
+	assert.Equal(t, changeMergedEvent.GetType().String(), msg.Header.Get("ce-type"), "CloudEvent type must be the CDEvent type")
+	assert.Equal(t, "2025-02-12T09:59:00Z", msg.Header.Get("ce-time"), "CloudEvent time must be the CDEvent timestamp")
+	assert.Equal(t, "2025-02-12T10:00:00Z", msg.Header.Get("ce-ingesttime"), "CloudEvent must have the ingest time")
+	assert.Equal(t, "https://adapter.example.com/schemas/customdata/gitea.push/v1.json", msg.Header.Get("ce-dataschema"), "CloudEvent must have the data schema")
+
+	var data map[string]interface{}
+	require.NoError(t, json.Unmarshal(msg.Data, &data), "message data must be the CDEvent")
+	assert.Contains(t, data, "context", "message data must be the CDEvent")
+}
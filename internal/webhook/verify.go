@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	ErrMissingSignature error = errors.New("Webhook request has no signature")
+	ErrInvalidSignature error = errors.New("Webhook request signature does not match any configured secret")
+)
+
+// Verifier checks that an incoming webhook request was sent by a source that
+// knows one of the configured shared secrets.
+type Verifier interface {
+	Verify(header http.Header, body []byte) error
+}
+
+type hmacVerifier struct {
+	header  string
+	prefix  string
+	secrets [][]byte
+}
+
+// NewGiteaVerifier verifies the hex encoded HMAC-SHA256 of the body sent by
+// Gitea in the X-Gitea-Signature header.
+func NewGiteaVerifier(secrets []string) Verifier {
+	return &hmacVerifier{
+		header:  "X-Gitea-Signature",
+		secrets: toBytes(secrets),
+	}
+}
+
+// NewGitHubVerifier verifies the "sha256=" prefixed HMAC-SHA256 of the body
+// sent by GitHub in the X-Hub-Signature-256 header.
+func NewGitHubVerifier(secrets []string) Verifier {
+	return &hmacVerifier{
+		header:  "X-Hub-Signature-256",
+		prefix:  "sha256=",
+		secrets: toBytes(secrets),
+	}
+}
+
+func (v *hmacVerifier) Verify(header http.Header, body []byte) error {
+	signature := header.Get(v.header)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	signature, found := strings.CutPrefix(signature, v.prefix)
+	if !found {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	for _, secret := range v.secrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		if hmac.Equal(mac.Sum(nil), expected) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+type tokenVerifier struct {
+	header  string
+	secrets [][]byte
+}
+
+// NewGitLabVerifier compares the plain secret token sent by GitLab in the
+// X-Gitlab-Token header.
+func NewGitLabVerifier(secrets []string) Verifier {
+	return &tokenVerifier{
+		header:  "X-Gitlab-Token",
+		secrets: toBytes(secrets),
+	}
+}
+
+func (v *tokenVerifier) Verify(header http.Header, body []byte) error {
+	token := header.Get(v.header)
+	if token == "" {
+		return ErrMissingSignature
+	}
+
+	for _, secret := range v.secrets {
+		if subtle.ConstantTimeCompare([]byte(token), secret) == 1 {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// ReadSecretsFile reads one secret per line from the given file, ignoring
+// blank lines, so that several secrets can be active during rotation.
+func ReadSecretsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var secrets []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if secret := strings.TrimSpace(scanner.Text()); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+func toBytes(secrets []string) [][]byte {
+	b := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		b = append(b, []byte(secret))
+	}
+	return b
+}
@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hmacSignature(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifiers(t *testing.T) {
+
+	body := "{\"foo\": \"bar\"}"
+	secrets := []string{"oldsecret", "newsecret"}
+
+	for _, tc := range []struct {
+		title         string
+		verifier      Verifier
+		header        http.Header
+		expectedError error
+	}{
+		{
+			title:    "Gitea signature made with current secret is accepted",
+			verifier: NewGiteaVerifier(secrets),
+			header:   http.Header{"X-Gitea-Signature": {hmacSignature("newsecret", body)}},
+		},
+		{
+			title:    "Gitea signature made with rotated out secret is accepted",
+			verifier: NewGiteaVerifier(secrets),
+			header:   http.Header{"X-Gitea-Signature": {hmacSignature("oldsecret", body)}},
+		},
+		{
+			title:         "Gitea signature made with unknown secret is rejected",
+			verifier:      NewGiteaVerifier(secrets),
+			header:        http.Header{"X-Gitea-Signature": {hmacSignature("unknown", body)}},
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			title:         "Gitea request without signature is rejected",
+			verifier:      NewGiteaVerifier(secrets),
+			header:        http.Header{},
+			expectedError: ErrMissingSignature,
+		},
+		{
+			title:         "Gitea signature that is not hex is rejected",
+			verifier:      NewGiteaVerifier(secrets),
+			header:        http.Header{"X-Gitea-Signature": {"notahexvalue"}},
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			title:    "GitHub signature with sha256 prefix is accepted",
+			verifier: NewGitHubVerifier(secrets),
+			header:   http.Header{"X-Hub-Signature-256": {"sha256=" + hmacSignature("newsecret", body)}},
+		},
+		{
+			title:         "GitHub signature without sha256 prefix is rejected",
+			verifier:      NewGitHubVerifier(secrets),
+			header:        http.Header{"X-Hub-Signature-256": {hmacSignature("newsecret", body)}},
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			title:    "GitLab token matching a secret is accepted",
+			verifier: NewGitLabVerifier(secrets),
+			header:   http.Header{"X-Gitlab-Token": {"oldsecret"}},
+		},
+		{
+			title:         "GitLab token not matching any secret is rejected",
+			verifier:      NewGitLabVerifier(secrets),
+			header:        http.Header{"X-Gitlab-Token": {"unknown"}},
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			title:         "GitLab request without token is rejected",
+			verifier:      NewGitLabVerifier(secrets),
+			header:        http.Header{},
+			expectedError: ErrMissingSignature,
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			err := tc.verifier.Verify(tc.header, []byte(body))
+			if tc.expectedError != nil {
+				assert.Equal(t, tc.expectedError, err)
+			} else {
+				assert.NoError(t, err, "verification should succeed")
+			}
+		})
+	}
+}
+
+func TestReadSecretsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	require.NoError(t, os.WriteFile(path, []byte("first\n\n  second  \n"), 0600), "failed to write secrets file for test")
+
+	secrets, err := ReadSecretsFile(path)
+	require.NoError(t, err, "no error should be returned when reading secrets file")
+	assert.Equal(t, []string{"first", "second"}, secrets)
+}
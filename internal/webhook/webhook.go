@@ -8,20 +8,97 @@ import (
 	"log/slog"
 	"mime"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/transport"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// sources lists the known webhook senders and the header carrying their event
+// name. Gitea also sends X-GitHub-Event for compatibility, so it must be first.
+var sources = []struct {
+	name        string
+	eventHeader string
+}{
+	{name: "gitea", eventHeader: "X-Gitea-Event"},
+	{name: "github", eventHeader: "X-GitHub-Event"},
+	{name: "gitlab", eventHeader: "X-Gitlab-Event"},
+}
+
+// giteaReviewEventTypes are the X-Gitea-Event-Type values of pull request
+// reviews, which have translators of their own.
+var giteaReviewEventTypes = []string{"pull_request_review_approved", "pull_request_review_rejected", "pull_request_review_comment"}
+
 type webhook struct {
-	logger *slog.Logger
+	logger               *slog.Logger
+	verifiers            map[string]Verifier
+	verificationFailures *prometheus.CounterVec
+}
+
+// New creates a webhook whose handler rejects requests from any source that
+// has a verifier in the given map (keyed by source name, e.g. "gitea") unless
+// the request passes verification. Once any verifier is given, requests from
+// sources without one, including unknown sources, are rejected as well.
+func New(logger *slog.Logger, registry prometheus.Registerer, verifiers map[string]Verifier) *webhook {
+	return &webhook{
+		logger:    logger,
+		verifiers: verifiers,
+		verificationFailures: promauto.With(registry).NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "webhook_verification_failures_total",
+				Help: "Tracks the number of webhook requests rejected by signature verification.",
+			}, []string{"source", "reason"},
+		),
+	}
+}
+
+// forwardedHeaderPrefixes are those of the headers that webhook senders
+// describe a delivery with, which are kept on the message for translators.
+var forwardedHeaderPrefixes = []string{"X-Gitea-", "X-Gogs-", "X-Github-", "X-Gitlab-"}
+
+// secretHeaders carry signatures or tokens and are never forwarded.
+var secretHeaders = map[string]bool{
+	"X-Gitea-Signature": true,
+	"X-Gogs-Signature":  true,
+	"X-Gitlab-Token":    true,
+}
+
+// forwardedHeaders returns the headers of the request that describe the
+// webhook delivery, such as its event and delivery id.
+func forwardedHeaders(header http.Header) nats.Header {
+	forwarded := nats.Header{}
+	for key, values := range header {
+		key = http.CanonicalHeaderKey(key)
+		if secretHeaders[key] {
+			continue
+		}
+		for _, prefix := range forwardedHeaderPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				forwarded[key] = values
+				break
+			}
+		}
+	}
+	return forwarded
 }
 
-func New(logger *slog.Logger) *webhook {
-	return &webhook{logger: logger}
+func detectSource(header http.Header) (string, string) {
+	for _, source := range sources {
+		if event := header.Get(source.eventHeader); event != "" {
+			return source.name, event
+		}
+	}
+	return "", ""
 }
 
-func (s *webhook) Handler(jsPublisher transport.JetstreamPublisher, subjectBase string) http.Handler {
+// Handler publishes webhooks to JetStream along with the headers describing
+// their delivery.
+func (s *webhook) Handler(jsPublisher transport.JetstreamMsgPublisher, subjectBase string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not supported", http.StatusNotImplemented)
@@ -45,12 +122,25 @@ func (s *webhook) Handler(jsPublisher transport.JetstreamPublisher, subjectBase
 			return
 		}
 
+		source, event := detectSource(r.Header)
+
+		// Gitea sends pull request reviews with the same X-Gitea-Event as other
+		// pull request events, only the event type header tells them apart.
+		if eventType := r.Header.Get("X-Gitea-Event-Type"); source == "gitea" && slices.Contains(giteaReviewEventTypes, eventType) {
+			event = eventType
+		}
+
 		var subject string
-		giteaEventHeader := r.Header.Get("X-Gitea-Event")
-		if giteaEventHeader != "" {
-			s.logger.Debug(fmt.Sprintf("Setting message subject based on X-Gitea-Event header: %s", giteaEventHeader))
-			subject = fmt.Sprintf("%s.gitea.%s", subjectBase, giteaEventHeader)
-		} else {
+		switch source {
+		case "gitea", "github":
+			s.logger.Debug(fmt.Sprintf("Setting message subject based on %s event header: %s", source, event))
+			subject = fmt.Sprintf("%s.%s.%s", subjectBase, source, event)
+		case "gitlab":
+			// GitLab names its events like "Merge Request Hook", which is turned into "merge_request"
+			s.logger.Debug(fmt.Sprintf("Setting message subject based on %s event header: %s", source, event))
+			event = strings.ReplaceAll(strings.ToLower(strings.TrimSuffix(event, " Hook")), " ", "_")
+			subject = fmt.Sprintf("%s.%s.%s", subjectBase, source, event)
+		default:
 			subject = fmt.Sprintf("%s.unknown", subjectBase)
 			s.logger.Warn(fmt.Sprintf("Found no known headers on which to route incoming webhook message, sending to subject: %s", subject))
 		}
@@ -62,6 +152,27 @@ func (s *webhook) Handler(jsPublisher transport.JetstreamPublisher, subjectBase
 			return
 		}
 
+		if verifier, exists := s.verifiers[source]; exists {
+			if err := verifier.Verify(r.Header, data); err != nil {
+				reason := "invalid"
+				if err == ErrMissingSignature {
+					reason = "missing"
+				}
+				s.verificationFailures.WithLabelValues(source, reason).Inc()
+				s.logger.Warn("Rejecting webhook that failed verification", "source", source, "error", err.Error())
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if len(s.verifiers) > 0 {
+			if source == "" {
+				source = "unknown"
+			}
+			s.verificationFailures.WithLabelValues(source, "unverified").Inc()
+			s.logger.Warn("Rejecting webhook from source without verifier", "source", source)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
 		if len(data) == 0 {
 			http.Error(w, "Received empty body", http.StatusBadRequest)
 			return
@@ -78,7 +189,11 @@ func (s *webhook) Handler(jsPublisher transport.JetstreamPublisher, subjectBase
 
 		s.logger.Debug(fmt.Sprintf("Publishing incoming webhook to Jetstream subject: %s", subject))
 
-		_, err = jsPublisher.Publish(ctx, subject, data)
+		_, err = jsPublisher.PublishMsg(ctx, &nats.Msg{
+			Subject: subject,
+			Data:    data,
+			Header:  forwardedHeaders(r.Header),
+		})
 		if err != nil {
 			s.logger.Error("Error when publishing event to Jetstream", "error", err.Error())
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
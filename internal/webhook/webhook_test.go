@@ -9,7 +9,9 @@ import (
 	"testing"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/mocks"
+	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -18,9 +20,11 @@ type webhookHandlerTC struct {
 	requestMethod          string
 	requestBody            string
 	requestHeaders         map[string][]string
+	verifiers              map[string]Verifier
 	jetstreamSubjectBase   string
 	expectedPublishSubject string
 	expectedPublishData    string
+	expectedPublishHeaders map[string]string
 	expectedResponseCode   int
 	expectedResponseBody   string
 }
@@ -40,7 +44,6 @@ func newDefaultWebhookHandlerTC() webhookHandlerTC {
 func TestWebhookHandler(t *testing.T) {
 
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
-	webhook := New(logger)
 
 	for _, tc := range []webhookHandlerTC{
 		func() webhookHandlerTC {
@@ -105,6 +108,140 @@ func TestWebhookHandler(t *testing.T) {
 			tc.expectedPublishSubject = "test.gitea.push"
 			return tc
 		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.gitea.pull_request_review_approved with X-Gitea-Event-Type header"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"pull_request_approved"}
+			tc.requestHeaders["X-Gitea-Event-Type"] = []string{"pull_request_review_approved"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.pull_request_review_approved"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.gitea.pull_request for review requests"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"pull_request"}
+			tc.requestHeaders["X-Gitea-Event-Type"] = []string{"pull_request_review_request"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.pull_request"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.gitea.issues for all kinds of issue events"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"issues"}
+			tc.requestHeaders["X-Gitea-Event-Type"] = []string{"issue_label"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.issues"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.gitea.issue_comment for comments on pull requests"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"issue_comment"}
+			tc.requestHeaders["X-Gitea-Event-Type"] = []string{"pull_request_comment"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.issue_comment"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.gitea.pull_request when X-Gitea-Event-Type is not a review"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"pull_request"}
+			tc.requestHeaders["X-Gitea-Event-Type"] = []string{"pull_request_sync"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.pull_request"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.github.pull_request with X-GitHub-Event header"
+			tc.requestHeaders["X-GitHub-Event"] = []string{"pull_request"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.github.pull_request"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to subject test.gitlab.merge_request with X-Gitlab-Event header"
+			tc.requestHeaders["X-Gitlab-Event"] = []string{"Merge Request Hook"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitlab.merge_request"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish to gitea subject when Gitea also sends X-GitHub-Event header"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"push"}
+			tc.requestHeaders["X-GitHub-Event"] = []string{"push"}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.push"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish when Gitea signature matches configured secret"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"push"}
+			tc.requestHeaders["X-Gitea-Signature"] = []string{hmacSignature("secret", tc.requestBody)}
+			tc.verifiers = map[string]Verifier{"gitea": NewGiteaVerifier([]string{"secret"})}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.push"
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "publish with delivery headers but without signature"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"push"}
+			tc.requestHeaders["X-Gitea-Delivery"] = []string{"f6266f16-1bf3-46a5-9ea4-602e06ead473"}
+			tc.requestHeaders["X-Gitea-Signature"] = []string{hmacSignature("secret", tc.requestBody)}
+			tc.requestHeaders["Authorization"] = []string{"Bearer token"}
+			tc.verifiers = map[string]Verifier{"gitea": NewGiteaVerifier([]string{"secret"})}
+			tc.jetstreamSubjectBase = "test"
+			tc.expectedPublishSubject = "test.gitea.push"
+			tc.expectedPublishHeaders = map[string]string{
+				"X-Gitea-Event":     "push",
+				"X-Gitea-Delivery":  "f6266f16-1bf3-46a5-9ea4-602e06ead473",
+				"X-Gitea-Signature": "",
+				"Authorization":     "",
+			}
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "unauthorized when Gitea signature does not match"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"push"}
+			tc.requestHeaders["X-Gitea-Signature"] = []string{hmacSignature("othersecret", tc.requestBody)}
+			tc.verifiers = map[string]Verifier{"gitea": NewGiteaVerifier([]string{"secret"})}
+			tc.expectedResponseCode = http.StatusUnauthorized
+			tc.expectedResponseBody = `Unauthorized`
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "unauthorized when Gitea signature is missing"
+			tc.requestHeaders["X-Gitea-Event"] = []string{"push"}
+			tc.verifiers = map[string]Verifier{"gitea": NewGiteaVerifier([]string{"secret"})}
+			tc.expectedResponseCode = http.StatusUnauthorized
+			tc.expectedResponseBody = `Unauthorized`
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "unauthorized from source without verifier when other source is verified"
+			tc.requestHeaders["X-GitHub-Event"] = []string{"push"}
+			tc.verifiers = map[string]Verifier{"gitea": NewGiteaVerifier([]string{"secret"})}
+			tc.expectedResponseCode = http.StatusUnauthorized
+			tc.expectedResponseBody = `Unauthorized`
+			return tc
+		}(),
+		func() webhookHandlerTC {
+			tc := newDefaultWebhookHandlerTC()
+			tc.title = "unauthorized without any known headers when a source is verified"
+			tc.verifiers = map[string]Verifier{"gitea": NewGiteaVerifier([]string{"secret"})}
+			tc.expectedResponseCode = http.StatusUnauthorized
+			tc.expectedResponseBody = `Unauthorized`
+			return tc
+		}(),
 	} {
 		t.Run(tc.title, func(t *testing.T) {
 
@@ -132,10 +269,27 @@ func TestWebhookHandler(t *testing.T) {
 				expectedData = []byte(tc.requestBody)
 			}
 
-			mockJS.On("Publish", expectedSubject, expectedData).Return(&jetstream.PubAck{Stream: "mockStream"}, nil)
+			mockJS.On("PublishMsg", mock.MatchedBy(func(msg *nats.Msg) bool {
+				if expectedSubject != mock.Anything && msg.Subject != expectedSubject {
+					return false
+				}
+				for key, value := range tc.expectedPublishHeaders {
+					if msg.Header.Get(key) != value {
+						return false
+					}
+				}
+				return string(msg.Data) == string(expectedData)
+			})).Return(&jetstream.PubAck{Stream: "mockStream"}, nil)
 
+			webhook := New(logger, prometheus.NewRegistry(), tc.verifiers)
 			webhook.Handler(mockJS, tc.jetstreamSubjectBase).ServeHTTP(rec, req)
 
+			if tc.expectedResponseCode == http.StatusOK {
+				mockJS.AssertNumberOfCalls(t, "PublishMsg", 1)
+			} else {
+				mockJS.AssertNotCalled(t, "PublishMsg", mock.Anything)
+			}
+
 			res := rec.Result()
 			defer res.Body.Close()
 
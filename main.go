@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/ansig/jetstream-cdevents-sink/internal/adapter"
+	"github.com/ansig/jetstream-cdevents-sink/internal/correlation"
 	"github.com/ansig/jetstream-cdevents-sink/internal/invalidmsg"
 	"github.com/ansig/jetstream-cdevents-sink/internal/metrics"
 	"github.com/ansig/jetstream-cdevents-sink/internal/sink"
@@ -32,26 +34,80 @@ import (
 var logger *slog.Logger
 
 var translators = map[string]translator.Webhook{
-	"gitea.push":         &translator.GiteaPush{},
-	"gitea.pull_request": &translator.GiteaPullRequest{},
-	"gitea.create":       &translator.GiteaCreate{},
-	"gitea.delete":       &translator.GiteaDelete{},
+	"gitea.push":                         &translator.GiteaPush{},
+	"gitea.pull_request":                 &translator.GiteaPullRequest{},
+	"gitea.create":                       &translator.GiteaCreate{},
+	"gitea.delete":                       &translator.GiteaDelete{},
+	"gitea.release":                      &translator.GiteaRelease{},
+	"gitea.package":                      &translator.GiteaPackage{},
+	"gitea.workflow_run":                 &translator.GiteaWorkflowRun{},
+	"gitea.workflow_job":                 &translator.GiteaWorkflowJob{},
+	"gitea.issues":                       &translator.GiteaIssues{},
+	"gitea.issue_comment":                &translator.GiteaIssueComment{},
+	"gitea.repository":                   &translator.GiteaRepository{},
+	"gitea.pull_request_review_approved": &translator.GiteaPullRequestReview{},
+	"gitea.pull_request_review_rejected": &translator.GiteaPullRequestReview{},
+	"gitea.pull_request_review_comment":  &translator.GiteaPullRequestReview{},
+	"gitea.ping":                         &translator.Ping{},
+	"github.push":                        &translator.GitHubPush{},
+	"github.pull_request":                &translator.GitHubPullRequest{},
+	"github.create":                      &translator.GitHubCreate{},
+	"github.delete":                      &translator.GitHubDelete{},
+	"github.ping":                        &translator.Ping{},
+	"gitlab.push":                        &translator.GitLabPush{},
+	"gitlab.tag_push":                    &translator.GitLabPush{},
+	"gitlab.merge_request":               &translator.GitLabMergeRequest{},
 }
 
 var addr = flag.String("listen-address", ":8080", "The address to listen on for HTTP requests.")
 var logLevel = flag.String("log-level", "info", "The event level to output.")
 
 type envConfig struct {
-	NATSUrl             string `envconfig:"NATS_URL" default:"http://localhost:4222" required:"true"`
-	WebhookStreamName   string `envconfig:"WEBHOOK_STREAM_NAME" default:"webhook-adapter-queue" required:"true"`
-	WebhookSubjectBase  string `envconfig:"WEBHOOK_SUBJECT_BASE" default:"webhooks" required:"true"`
-	WebhookConsumerName string `envconfig:"WEBHOOK_CONSUMER_NAME" default:"webhook-adapter" required:"true"`
-	InvMsgStreamName    string `envconfig:"INVALID_MESSAGES_STREAM_NAME" default:"invalid-messages-channel" required:"true"`
-	InvMsgSubjectBase   string `envconfig:"INVALID_MESSAGES_SUBJECT_BASE" default:"invalid" required:"true"`
-	InvMsgStreamMaxAge  string `envconfig:"INVALID_MESSAGES_STREAM_MAX_AGE" default:"48h" required:"true"`
-	EventStreamName     string `envconfig:"EVENT_STREAM_NAME" default:"cdevents" required:"true"`
-	EventSubjectBase    string `envconfig:"EVENT_SUBJECT_BASE" default:"dev.cdevents" required:"true"`
-	EventStreamMaxAge   string `envconfig:"EVENT_STREAM_MAX_AGE" default:"8808h" required:"true"`
+	NATSUrl                string `envconfig:"NATS_URL" default:"http://localhost:4222" required:"true"`
+	WebhookStreamName      string `envconfig:"WEBHOOK_STREAM_NAME" default:"webhook-adapter-queue" required:"true"`
+	WebhookSubjectBase     string `envconfig:"WEBHOOK_SUBJECT_BASE" default:"webhooks" required:"true"`
+	WebhookConsumerName    string `envconfig:"WEBHOOK_CONSUMER_NAME" default:"webhook-adapter" required:"true"`
+	WebhookMaxDeliver      int    `envconfig:"WEBHOOK_CONSUMER_MAX_DELIVER" default:"10" required:"true"`
+	WebhookAckWait         string `envconfig:"WEBHOOK_CONSUMER_ACK_WAIT" default:"30s" required:"true"`
+	WebhookBackOff         string `envconfig:"WEBHOOK_CONSUMER_BACKOFF"`
+	WebhookRetryDelay      string `envconfig:"WEBHOOK_RETRY_INITIAL_DELAY" default:"1s" required:"true"`
+	WebhookRetryMaxDelay   string `envconfig:"WEBHOOK_RETRY_MAX_DELAY" default:"5m" required:"true"`
+	AdapterWorkers         int    `envconfig:"ADAPTER_WORKERS" default:"4" required:"true"`
+	AdapterQueueSize       int    `envconfig:"ADAPTER_WORKER_QUEUE_SIZE" default:"16" required:"true"`
+	InvMsgStreamName       string `envconfig:"INVALID_MESSAGES_STREAM_NAME" default:"invalid-messages-channel" required:"true"`
+	InvMsgSubjectBase      string `envconfig:"INVALID_MESSAGES_SUBJECT_BASE" default:"invalid" required:"true"`
+	InvMsgStreamMaxAge     string `envconfig:"INVALID_MESSAGES_STREAM_MAX_AGE" default:"48h" required:"true"`
+	EventStreamName        string `envconfig:"EVENT_STREAM_NAME" default:"cdevents" required:"true"`
+	EventSubjectBase       string `envconfig:"EVENT_SUBJECT_BASE" default:"dev.cdevents" required:"true"`
+	CustomEventSubjectBase string `envconfig:"CUSTOM_EVENT_SUBJECT_BASE" default:"dev.cdeventsx" required:"true"`
+	EventStreamMaxAge      string `envconfig:"EVENT_STREAM_MAX_AGE" default:"8808h" required:"true"`
+	MergeBucketName        string `envconfig:"MERGE_CORRELATION_BUCKET_NAME" default:"merge-correlation" required:"true"`
+	MergeBucketTTL         string `envconfig:"MERGE_CORRELATION_TTL" default:"24h" required:"true"`
+	MergeWait              string `envconfig:"MERGE_CORRELATION_WAIT" default:"30s" required:"true"`
+
+	MergeBranches             []string `envconfig:"MERGE_BRANCHES"`
+	IgnorePushToOtherBranches bool     `envconfig:"IGNORE_PUSH_TO_OTHER_BRANCHES" default:"false"`
+	GiteaStatusContexts       []string `envconfig:"GITEA_STATUS_CONTEXTS"`
+	GiteaRefEvents            string   `envconfig:"GITEA_REF_EVENTS" default:"push"`
+
+	MappingFiles []string `envconfig:"MAPPING_FILES"`
+
+	ProcessTranslators       []string `envconfig:"PROCESS_TRANSLATORS"`
+	ProcessTranslatorTimeout string   `envconfig:"PROCESS_TRANSLATOR_TIMEOUT" default:"5s"`
+
+	OutputSpecVersion  string   `envconfig:"OUTPUT_SPEC_VERSION"`
+	OutputSpecVersions []string `envconfig:"OUTPUT_SPEC_VERSIONS"`
+
+	CustomDataPolicies []string `envconfig:"CUSTOM_DATA_POLICIES"`
+	CustomDataMaxSize  int      `envconfig:"CUSTOM_DATA_MAX_SIZE" default:"0"`
+	SchemaBaseURL      string   `envconfig:"SCHEMA_BASE_URL"`
+
+	GiteaWebhookSecrets      []string `envconfig:"GITEA_WEBHOOK_SECRETS"`
+	GiteaWebhookSecretsFile  string   `envconfig:"GITEA_WEBHOOK_SECRETS_FILE"`
+	GitHubWebhookSecrets     []string `envconfig:"GITHUB_WEBHOOK_SECRETS"`
+	GitHubWebhookSecretsFile string   `envconfig:"GITHUB_WEBHOOK_SECRETS_FILE"`
+	GitLabWebhookSecrets     []string `envconfig:"GITLAB_WEBHOOK_SECRETS"`
+	GitLabWebhookSecretsFile string   `envconfig:"GITLAB_WEBHOOK_SECRETS_FILE"`
 }
 
 func MustCreateStream(ctx context.Context, jetstream natsjs.JetStream, config natsjs.StreamConfig) natsjs.Stream {
@@ -76,6 +132,39 @@ func MustCreateStream(ctx context.Context, jetstream natsjs.JetStream, config na
 	return stream
 }
 
+// MustLoadSecrets combines the secrets given directly in the environment with
+// those read from the given file, if any.
+func MustLoadSecrets(secrets []string, file string) []string {
+	if file == "" {
+		return secrets
+	}
+
+	fileSecrets, err := webhook.ReadSecretsFile(file)
+	if err != nil {
+		logger.Error("Failed to read webhook secrets file", "file", file, "error", err.Error())
+		os.Exit(1)
+	}
+
+	return append(secrets, fileSecrets...)
+}
+
+// MustParseDurations parses a comma separated list of durations.
+func MustParseDurations(s string) []time.Duration {
+	var durations []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil {
+			logger.Error("Failed to parse duration", "value", part, "error", err)
+			os.Exit(1)
+		}
+		durations = append(durations, d)
+	}
+	return durations
+}
+
 func main() {
 
 	flag.Parse()
@@ -129,7 +218,7 @@ func main() {
 
 	MustCreateStream(startupCtx, jetstream, natsjs.StreamConfig{
 		Name:        env.EventStreamName,
-		Subjects:    []string{fmt.Sprintf("%s.>", env.EventSubjectBase)},
+		Subjects:    []string{fmt.Sprintf("%s.>", env.EventSubjectBase), fmt.Sprintf("%s.>", env.CustomEventSubjectBase)},
 		Description: "Output stream for Webhook",
 		Retention:   natsjs.LimitsPolicy,
 		MaxAge:      eventStreamMaxAge,
@@ -158,9 +247,18 @@ func main() {
 		Retention:   natsjs.WorkQueuePolicy,
 	})
 
+	webhookAckWait, err := time.ParseDuration(env.WebhookAckWait)
+	if err != nil {
+		logger.Error("Failed to parse consumer ack wait", "error", err)
+		os.Exit(1)
+	}
+
 	consumer, err := webhookStream.CreateOrUpdateConsumer(startupCtx, natsjs.ConsumerConfig{
-		Durable:   env.WebhookConsumerName,
-		AckPolicy: natsjs.AckExplicitPolicy,
+		Durable:    env.WebhookConsumerName,
+		AckPolicy:  natsjs.AckExplicitPolicy,
+		AckWait:    webhookAckWait,
+		MaxDeliver: env.WebhookMaxDeliver,
+		BackOff:    MustParseDurations(env.WebhookBackOff),
 	})
 
 	if err != nil {
@@ -168,14 +266,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	done := make(chan interface{})
-
-	messages := make(chan natsjs.Msg)
-	consContext, _ := consumer.Consume(func(msg natsjs.Msg) {
-		messages <- msg
-	})
+	reg := prometheus.NewRegistry()
 
-	var wg sync.WaitGroup
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
 
 	invalidMessageHandler := invalidmsg.NewJetStreamInvalidMsgHandler(
 		logger,
@@ -183,47 +279,198 @@ func main() {
 		env.InvMsgSubjectBase,
 	)
 
-	cdEventsAdapter := adapter.New(logger, nc, translators, invalidMessageHandler)
+	retryInitialDelay, err := time.ParseDuration(env.WebhookRetryDelay)
+	if err != nil {
+		logger.Error("Failed to parse retry initial delay", "error", err)
+		os.Exit(1)
+	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer consContext.Stop()
-		for {
-			select {
-			case msg := <-messages:
-				if err := cdEventsAdapter.Process(msg); err != nil {
-					logger.Error("Failed to process message", "error", err.Error())
-				}
-			case <-done:
-				logger.Info("Stopped processing messages")
-				return
-			}
+	retryMaxDelay, err := time.ParseDuration(env.WebhookRetryMaxDelay)
+	if err != nil {
+		logger.Error("Failed to parse retry max delay", "error", err)
+		os.Exit(1)
+	}
+
+	retryPolicy := adapter.RetryPolicy{
+		MaxDeliver:   env.WebhookMaxDeliver,
+		InitialDelay: retryInitialDelay,
+		MaxDelay:     retryMaxDelay,
+	}
+
+	mergeBranchPatterns, err := translator.ParseBranchPatterns(env.MergeBranches)
+	if err != nil {
+		logger.Error("Failed to parse merge branches", "error", err)
+		os.Exit(1)
+	}
+
+	branchPolicy := translator.BranchPolicy{
+		Patterns:            mergeBranchPatterns,
+		IgnoreOtherBranches: env.IgnorePushToOtherBranches,
+	}
+
+	mergeBucketTTL, err := time.ParseDuration(env.MergeBucketTTL)
+	if err != nil {
+		logger.Error("Failed to parse merge correlation TTL", "error", err)
+		os.Exit(1)
+	}
+
+	mergeBucket, err := jetstream.CreateOrUpdateKeyValue(startupCtx, natsjs.KeyValueConfig{
+		Bucket:      env.MergeBucketName,
+		Description: "Merges reported by webhooks",
+		TTL:         mergeBucketTTL,
+	})
+	if err != nil {
+		logger.Error("Failed to create merge correlation bucket", "error", err)
+		os.Exit(1)
+	}
+
+	mergeWait, err := time.ParseDuration(env.MergeWait)
+	if err != nil {
+		logger.Error("Failed to parse merge correlation wait", "error", err)
+		os.Exit(1)
+	}
+
+	mergeRecorder := correlation.NewKeyValueMergeRecorder(mergeBucket)
+
+	giteaRefEvents, err := translator.ParseRefEvents(env.GiteaRefEvents)
+	if err != nil {
+		logger.Error("Failed to parse Gitea ref events", "error", err)
+		os.Exit(1)
+	}
+
+	translators["gitea.push"] = &translator.GiteaPush{
+		BranchPolicy: branchPolicy,
+		Merges:       mergeRecorder,
+		MergeWait:    mergeWait,
+		RefEvents:    giteaRefEvents,
+	}
+	translators["github.push"] = &translator.GitHubPush{BranchPolicy: branchPolicy}
+	translators["gitlab.push"] = &translator.GitLabPush{BranchPolicy: branchPolicy}
+	translators["gitlab.tag_push"] = &translator.GitLabPush{BranchPolicy: branchPolicy}
+	translators["gitea.create"] = &translator.GiteaCreate{RefEvents: giteaRefEvents}
+	translators["gitea.delete"] = &translator.GiteaDelete{RefEvents: giteaRefEvents}
+	translators["gitea.pull_request"] = &translator.GiteaPullRequest{Merges: mergeRecorder}
+
+	statusRules, err := translator.ParseStatusRules(env.GiteaStatusContexts)
+	if err != nil {
+		logger.Error("Failed to parse Gitea status contexts", "error", err)
+		os.Exit(1)
+	}
+
+	translators["gitea.status"] = &translator.GiteaStatus{
+		StatusPolicy: translator.StatusPolicy{Rules: statusRules},
+	}
+
+	mappingTranslators, err := translator.LoadMappingFiles(env.MappingFiles)
+	if err != nil {
+		logger.Error("Failed to load mapping files", "error", err)
+		os.Exit(1)
+	}
+
+	for name, webhookTranslator := range mappingTranslators {
+		if _, exists := translators[name]; exists {
+			logger.Info("Mapping file replaces built-in translator", "translator", name)
 		}
-	}()
+		translators[name] = webhookTranslator
+	}
+
+	processCommands, err := translator.ParseProcessTranslators(env.ProcessTranslators)
+	if err != nil {
+		logger.Error("Failed to parse translator processes", "error", err)
+		os.Exit(1)
+	}
+
+	processTimeout, err := time.ParseDuration(env.ProcessTranslatorTimeout)
+	if err != nil {
+		logger.Error("Failed to parse translator process timeout", "error", err)
+		os.Exit(1)
+	}
+
+	var processTranslators []io.Closer
+	for name, command := range processCommands {
+		if _, exists := translators[name]; exists {
+			logger.Info("Translator process replaces translator", "translator", name)
+		}
+		processTranslator := translator.NewProcess(logger, command, processTimeout)
+		processTranslators = append(processTranslators, processTranslator)
+		translators[name] = processTranslator
+	}
+
+	customDataPolicies, err := translator.ParseCustomDataPolicies(env.CustomDataPolicies, env.CustomDataMaxSize)
+	if err != nil {
+		logger.Error("Failed to parse custom data policies", "error", err)
+		os.Exit(1)
+	}
+
+	specVersions, err := translator.ParseSpecVersions(env.OutputSpecVersions, env.OutputSpecVersion)
+	if err != nil {
+		logger.Error("Failed to parse output spec versions", "error", err)
+		os.Exit(1)
+	}
+
+	for name, webhookTranslator := range translators {
+		webhookTranslator = translator.WithCustomDataPolicy(webhookTranslator, customDataPolicies.For(name))
+		translators[name] = translator.WithSpecVersion(webhookTranslator, specVersions.For(name))
+	}
+
+	cloudEventPublisher := transport.NewCloudEventJetStreamPublisher(jetstream)
+
+	cdEventsAdapter := adapter.New(logger, reg, cloudEventPublisher, translators, invalidMessageHandler, retryPolicy, env.SchemaBaseURL)
+
+	workerPool := adapter.NewPool(logger, reg, cdEventsAdapter, env.AdapterWorkers, env.AdapterQueueSize, webhookAckWait/3)
+	workerPool.Start()
+
+	consContext, err := consumer.Consume(func(msg natsjs.Msg) {
+		workerPool.Submit(msg)
+	})
+	if err != nil {
+		logger.Error("Could not start consuming messages", "error", err.Error())
+		os.Exit(1)
+	}
+
+	// Queue subscribed so that only one instance handles each advisory.
+	maxDeliveriesSubject := fmt.Sprintf("$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES.%s.%s", env.WebhookStreamName, env.WebhookConsumerName)
+	maxDeliveriesSub, err := nc.QueueSubscribe(maxDeliveriesSubject, env.WebhookConsumerName, func(msg *nats.Msg) {
+		if err := cdEventsAdapter.ProcessMaxDeliveries(webhookStream, msg.Data); err != nil {
+			logger.Error("Failed to process max deliveries advisory", "error", err)
+		}
+	})
+	if err != nil {
+		logger.Error("Could not subscribe to max deliveries advisories", "error", err.Error())
+		os.Exit(1)
+	}
+
+	var wg sync.WaitGroup
 
 	logger.Info("JetStream consumer ready and listening...")
 
 	logger.Info("Starting server...")
 
-	webhook := webhook.New(logger)
-	sink := sink.New(logger)
-
-	cloudEventPublisher := transport.NewCloudEventJetStreamPublisher(nc)
+	verifiers := map[string]webhook.Verifier{}
+	if secrets := MustLoadSecrets(env.GiteaWebhookSecrets, env.GiteaWebhookSecretsFile); len(secrets) > 0 {
+		verifiers["gitea"] = webhook.NewGiteaVerifier(secrets)
+	}
+	if secrets := MustLoadSecrets(env.GitHubWebhookSecrets, env.GitHubWebhookSecretsFile); len(secrets) > 0 {
+		verifiers["github"] = webhook.NewGitHubVerifier(secrets)
+	}
+	if secrets := MustLoadSecrets(env.GitLabWebhookSecrets, env.GitLabWebhookSecretsFile); len(secrets) > 0 {
+		verifiers["gitlab"] = webhook.NewGitLabVerifier(secrets)
+	}
 
-	reg := prometheus.NewRegistry()
+	for source := range verifiers {
+		logger.Info(fmt.Sprintf("Verifying webhooks from source: %s", source))
+	}
 
-	reg.MustRegister(
-		collectors.NewGoCollector(),
-		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
-	)
+	webhookHandler := webhook.New(logger, reg, verifiers)
+	sink := sink.New(logger, specVersions.For("*"))
 
 	middleware := metrics.NewMiddleware(reg, nil)
 
 	mux := http.NewServeMux()
-	mux.Handle("/webhook", middleware.WrapHandler("/webhook", webhook.Handler(jetstream, env.WebhookSubjectBase)))
+	mux.Handle("/webhook", middleware.WrapHandler("/webhook", webhookHandler.Handler(jetstream, env.WebhookSubjectBase)))
 	mux.Handle("/sink", middleware.WrapHandler("/sink", sink.Handler(cloudEventPublisher)))
 	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.Handle("/schemas/", http.FileServerFS(translator.Schemas))
 
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -271,13 +518,18 @@ func main() {
 		os.Exit(1)
 	}
 
-	close(done)
-
 	logger.Info("Gracefully shutting down...")
 
 	c := make(chan struct{})
 	go func() {
 		defer close(c)
+		consContext.Stop()
+		maxDeliveriesSub.Unsubscribe()
+		workerPool.Stop()
+		for _, processTranslator := range processTranslators {
+			processTranslator.Close()
+		}
+		logger.Info("Stopped processing messages")
 		wg.Wait()
 	}()
 